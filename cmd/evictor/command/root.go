@@ -1,9 +1,16 @@
 package command
 
 import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/api"
+	"auto-failover-tikv-leader-evict/pkg/election"
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
 	"auto-failover-tikv-leader-evict/pkg/evictor"
+	"auto-failover-tikv-leader-evict/pkg/kubehelper"
 	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
 	"context"
+	"fmt"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"os"
@@ -22,43 +29,155 @@ var config = evictor.Config{
 	PendingForRecover: 0,
 }
 var debug = false
+var statusAddress = ""
+
+// electionConfig configures the leader election between evictor replicas; only the leader runs evictor.
+type electionConfig struct {
+	Backend  string
+	LockFile string
+	Id       string
+	TTL      time.Duration
+}
+
+var electionConf = electionConfig{}
+
+const electionNone = "none"
+const electionFile = "file"
+const electionPdEtcd = "pd-etcd"
 
 const defaultInterval = 15 * time.Second
 
 func NewRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
-		Run: run,
+		RunE: run,
+		// errors are logged by main, usage is only printed for invalid flags
+		SilenceErrors: true,
 	}
 	rootCmd.Flags().StringVar(&config.PrometheusAddress, "prometheus", "", "address of prometheus")
 	rootCmd.MarkFlagRequired("prometheus")
+	rootCmd.Flags().StringVar(&config.PrometheusQuery, "prometheus-query", promhelper.DefaultLatencyQuery, "template of the query for latency in seconds, $matchers is replaced with --prometheus-matcher")
+	rootCmd.Flags().StringVar(&config.PrometheusSuccessQuery, "prometheus-success-query", promhelper.DefaultSuccessQuery, "template of the query for probe success on the same links, failed probes exceed any threshold; empty disables it")
+	rootCmd.Flags().StringVar(&config.PrometheusSourceLabel, "prometheus-source-label", promhelper.DefaultSourceLabel, "label of the source of a link in the query result")
+	rootCmd.Flags().StringVar(&config.PrometheusTargetLabel, "prometheus-target-label", promhelper.DefaultTargetLabel, "label of the target of a link in the query result")
+	rootCmd.Flags().StringArrayVar(&config.PrometheusMatchers, "prometheus-matcher", nil, "extra label matcher of the query like cluster=\"prod-a\", could be repeated")
 	rootCmd.Flags().StringVar(&config.PdAddress, "pd", "", "address of pd")
 	rootCmd.MarkFlagRequired("pd")
-	rootCmd.Flags().StringVar(&config.PdVersion, "pd-version", "v3", "pd version; available values: v3, v4")
+	rootCmd.Flags().StringVar(&config.PdVersion, "pd-version", "", "override the detected pd version; available values: v3, v4, v5, v6, v7")
+	rootCmd.Flags().DurationVar(&config.PdVersionCheckInterval, "pd-version-check-interval", 10*time.Minute, "interval for re-detecting pd version after upgrades; 0 disables it")
+	rootCmd.Flags().StringVar(&config.PdExecutor, "pd-executor", evictor.ExecutorPdCtl, "the way to operate pd; available values: pd-ctl, http")
+	rootCmd.Flags().StringVar(&config.Action, "action", evictor.ActionEvictLeader, "the way to mitigate unhealthy tikv; available values: evict-leader, evict-slow-store")
+	rootCmd.Flags().StringVar(&config.MappingFile, "mapping-file", "", "file which maps probe endpoints to tikv stores, it is consulted before matching addresses; empty disables it")
+	rootCmd.Flags().StringVar(&config.MappingFormat, "mapping-format", addrhelper.MappingStatic, "format of --mapping-file; available values: static, tiup, ansible")
+	rootCmd.Flags().StringVar(&config.KubernetesNamespace, "kubernetes-namespace", "", "resolve the DNS names of tikv pods in this namespace to their pod and node IPs by kubernetes api; empty disables it")
+	rootCmd.Flags().StringVar(&config.KubernetesSelector, "kubernetes-selector", kubehelper.DefaultSelector, "label selector of tikv pods")
+	rootCmd.Flags().StringVar(&config.KubernetesAPIServer, "kubernetes-api-server", "", "address of kubernetes api server without authentication, e.g. kubectl proxy; empty uses the in-cluster service account")
+	rootCmd.Flags().BoolVar(&config.KubernetesLogMapping, "kubernetes-log-mapping", false, "log the mapping of tikv pods once it changes")
+	rootCmd.Flags().Float64Var(&config.UnstableLeaderWeight, "unstable-leader-weight", 0, "leader weight set on unstable tikv, which will be restored after it becomes healthy; 0 disables it")
+	rootCmd.Flags().StringVar(&config.StateFile, "state-file", "evictor-state.json", "local file which keeps the tikv evicted by this tool, others will never be recovered by this tool; empty keeps them in memory only")
+	rootCmd.Flags().StringVar(&config.StateStore, "state-store", evictor.StateStoreFile, "where to keep the state; available values: file, pd-etcd")
+	rootCmd.Flags().StringVar(&electionConf.Backend, "election", electionNone, "leader election between evictor replicas, only the leader evicts and recovers; available values: none, file, pd-etcd")
+	rootCmd.Flags().StringVar(&electionConf.LockFile, "election-lock-file", "evictor.lock", "lock file for --election=file")
+	rootCmd.Flags().StringVar(&electionConf.Id, "election-id", defaultElectionId(), "identity of this replica in leader election")
+	rootCmd.Flags().DurationVar(&electionConf.TTL, "election-ttl", 15*time.Second, "a leader which fails to renew its leadership for this duration will be replaced")
 	rootCmd.Flags().UintVar(&config.MaxEvicted, "max-evicted", 2, "max number of tikv which could be evicted leader by this tool")
+	rootCmd.Flags().Float64Var(&config.MaxEvictedPercent, "max-evicted-percent", 0, "max percentage of all tikv which could be evicted leader, including the tikv evicted by others; 0 disables it")
+	rootCmd.Flags().StringSliceVar(&config.LocationLabels, "location-labels", nil, "keys of store labels, like zone,rack,host; a tikv is not evicted if no other tikv with the same label could serve leaders, unless it is the only tikv with the last label")
+	rootCmd.Flags().UintVar(&config.MaxEvictedPerDomain, "max-evicted-per-domain", 0, "max number of evicted tikv with the same value of any --location-labels; 0 disables it")
 	rootCmd.Flags().DurationVar(&config.Interval, "interval", defaultInterval, "interval for refresh latency metrics")
 	rootCmd.Flags().DurationVar(&config.Threshold, "threshold", time.Second, "a link which hold a latency longer than threshold will be treated as bad link")
+	rootCmd.Flags().DurationVar(&config.RecoverThreshold, "recover-threshold", 0, "a link which hold a latency shorter than recover threshold will be treated as good link, it should not be above --threshold; 0 uses --threshold")
+	rootCmd.Flags().Float64Var(&config.LossThreshold, "loss-threshold", 0, "a link which loses more than this percentage of probes in --pending-for-evict will be treated as bad link, it requires --prometheus-success-query; 0 disables it")
+	rootCmd.Flags().Var(&config.EvictRule, "evict-rule", "how the samples in --pending-for-evict exceed --threshold for a bad link: all, a percentage like 90% or a percentile like p90")
+	rootCmd.Flags().Var(&config.RecoverRule, "recover-rule", "how the samples in --pending-for-recover stay below --threshold for a good link: all, a percentage like 90% or a percentile like p90")
 	rootCmd.Flags().UintVar(&config.BadLinkFuseThreshold, "bad-link-fuse-threshold", 2, "a node which node the threshold of bad link bigger than that will be treated as unhealthy")
+	rootCmd.Flags().DurationVar(&config.StaleThreshold, "stale-threshold", time.Minute, "a link whose last sample is older than this duration will be treated as stale, a node without fresh links is unknown; 0 only treats links without samples as stale")
+	rootCmd.Flags().StringVar(&config.UnknownEvictPolicy, "unknown-evict-policy", evictor.UnknownIgnore, "whether to evict unknown tikv; available values: ignore, evict")
+	rootCmd.Flags().StringVar(&config.UnknownRecoverPolicy, "unknown-recover-policy", evictor.UnknownKeep, "whether to recover evicted tikv while unknown; available values: keep, recover")
+	rootCmd.Flags().Float64Var(&config.DegradedBadLinks, "degraded-bad-links", 50, "percentage of bad links not explained by a few slow nodes over which the cluster is degraded, it will not evict or recover any tikv until the cluster stabilizes for --pending-for-recover; 0 disables it")
+	rootCmd.Flags().Float64Var(&config.DegradedUnhealthyNodes, "degraded-unhealthy-nodes", 50, "percentage of unhealthy nodes over which the cluster is degraded; 0 disables it")
+	rootCmd.Flags().BoolVar(&config.DegradedProber, "degraded-prober", true, "the cluster is degraded if all bad links are probed from a prober which is not probed by others")
+	rootCmd.Flags().DurationVar(&config.FlapWindow, "flap-window", 30*time.Minute, "a tikv evicted again within this duration after recovered is flapping, each flap doubles how long it should keep healthy before recovered; 0 disables it")
+	rootCmd.Flags().DurationVar(&config.FlapMaxHold, "flap-max-hold", 2*time.Hour, "max duration for which a flapping tikv should keep healthy before recovered; 0 means unlimited")
+	rootCmd.Flags().DurationVar(&config.FlapDecay, "flap-decay", time.Hour, "one flap of a tikv is forgiven for each this duration without flapping; 0 never forgives")
 	rootCmd.Flags().DurationVar(&config.PendingForEvict, "pending-for-evict", time.Minute, "an unhealthy tikv node will be evicted after this duration")
 	rootCmd.Flags().DurationVar(&config.PendingForRecover, "pending-for-recover", 2*defaultInterval, "an evicted tikv with stable latency will recover at least after this duration")
+	rootCmd.Flags().StringVar(&statusAddress, "status-address", "", "address for serving the status api and metrics, e.g. :8080; empty disables it")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "print debug logs")
 	return rootCmd
 }
 
-func run(cmd *cobra.Command, args []string) {
+// run returns an error if evictor fails to start or exits abnormally, so the process exits non-zero and could be
+// restarted by its supervisor.
+func run(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
 	if debug {
 		log.EnableDebug()
 	}
 	log.L().With(zap.Any("config", config)).Info("evictor configurations")
 	instance, err := evictor.NewEvictor(config)
 	if err != nil {
-		log.L().With(zap.Error(err)).Error("failed to initialize evictor")
-		return
+		return fmt.Errorf("failed to initialize evictor: %v", err)
+	}
+	elector, err := newElector()
+	if err != nil {
+		return fmt.Errorf("failed to initialize leader election: %v", err)
+	}
+	ctx := makeContext()
+	var isLeader func() bool
+	if elector != nil {
+		elector.OnChange = instance.Metrics().ObserveLeadership
+		instance.Metrics().Leader.Set(0)
+		isLeader = elector.IsLeader
+	}
+	if statusAddress != "" {
+		go func() {
+			if err := api.Serve(ctx, statusAddress, api.NewHandler(instance, isLeader, instance.Metrics().Registry)); err != nil {
+				log.L().With(zap.Error(err)).Error("failed to serve status api")
+			}
+		}()
+	}
+	if elector == nil {
+		err = instance.Run(ctx)
+	} else {
+		err = elector.Run(ctx, instance.Run)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute evictor: %v", err)
 	}
-	err = instance.Run(makeContext())
+	return nil
+}
+
+func newElector() (*election.Elector, error) {
+	var lock election.Lock
+	switch electionConf.Backend {
+	case electionNone, "":
+		return nil, nil
+	case electionFile:
+		lock = election.NewFileLock(electionConf.LockFile)
+	case electionPdEtcd:
+		// replicas on different hosts should share the state, otherwise the new leader never recovers the tikv
+		// evicted by the previous one, since they are not owned by itself
+		if config.StateStore != evictor.StateStorePdEtcd {
+			return nil, fmt.Errorf("--election=%s requires --state-store=%s, so the tikv evicted by the previous leader could be recovered",
+				electionPdEtcd, evictor.StateStorePdEtcd)
+		}
+		lock = election.NewEtcdLock(etcdhelper.NewClient(config.PdAddress), election.DefaultEtcdKey)
+	default:
+		return nil, fmt.Errorf("unsupported election backend %s", electionConf.Backend)
+	}
+	if electionConf.TTL < 3*time.Second {
+		return nil, fmt.Errorf("election ttl should be at least 3s")
+	}
+	log.L().With(zap.Any("election", electionConf)).Info("leader election enabled")
+	return election.NewElector(lock, electionConf.Id, electionConf.TTL), nil
+}
+
+func defaultElectionId() string {
+	hostname, err := os.Hostname()
 	if err != nil {
-		log.L().With(zap.Error(err)).Error("failed to execute evictor")
-		return
+		hostname = "unknown"
 	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 func makeContext() context.Context {
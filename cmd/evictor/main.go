@@ -4,11 +4,12 @@ import (
 	"auto-failover-tikv-leader-evict/cmd/evictor/command"
 	"auto-failover-tikv-leader-evict/pkg/log"
 	"go.uber.org/zap"
+	"os"
 )
 
 func main() {
 	if err := command.NewRootCmd().Execute(); err != nil {
 		log.L().With(zap.Error(err)).Error("failed to execute")
-		return
+		os.Exit(1)
 	}
 }
@@ -7,4 +7,5 @@ require (
 	github.com/prometheus/common v0.4.0
 	github.com/spf13/cobra v1.1.1
 	go.uber.org/zap v1.10.0
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,58 @@
+package addrhelper
+
+import (
+	"net"
+	"strings"
+)
+
+// SplitHostPort splits address into host and port; port is empty if address has no port.
+// It accepts "host:port", "host", "[ipv6]:port", "[ipv6]" and bare IPv6 like "fe80::1%eth0";
+// a bare IPv6 is never split, since its last group could not be told from a port.
+func SplitHostPort(address string) (host, port string) {
+	address = strings.TrimSpace(address)
+	if isIP(address) {
+		return address, ""
+	}
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		return address[1 : len(address)-1], ""
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, ""
+	}
+	return host, port
+}
+
+// NormalizeHost lowercases host and trims the trailing dot of fully qualified domain names,
+// and formats IPs in their canonical form without brackets, e.g. "[2001:DB8:0::1]" becomes "2001:db8::1".
+func NormalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	ip, zone := splitZone(host)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if zone != "" {
+			return parsed.String() + "%" + zone
+		}
+		return parsed.String()
+	}
+	return host
+}
+
+// HostOf returns the normalized host of address.
+func HostOf(address string) string {
+	host, _ := SplitHostPort(address)
+	return NormalizeHost(host)
+}
+
+// isIP reports whether host is an IP, with an optional IPv6 zone.
+func isIP(host string) bool {
+	ip, _ := splitZone(host)
+	return net.ParseIP(ip) != nil
+}
+
+func splitZone(host string) (ip, zone string) {
+	if index := strings.LastIndex(host, "%"); index >= 0 {
+		return host[:index], host[index+1:]
+	}
+	return host, ""
+}
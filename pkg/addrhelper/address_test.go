@@ -0,0 +1,59 @@
+package addrhelper
+
+import "testing"
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		wantHost string
+		wantPort string
+	}{
+		{"ipv4 with port", "10.0.0.1:20160", "10.0.0.1", "20160"},
+		{"ipv4 without port", "10.0.0.1", "10.0.0.1", ""},
+		{"hostname with port", "tikv-0.tikv-peer:20160", "tikv-0.tikv-peer", "20160"},
+		{"hostname without port", "tikv-0.tikv-peer", "tikv-0.tikv-peer", ""},
+		{"bracketed ipv6 with port", "[2001:db8::1]:20160", "2001:db8::1", "20160"},
+		{"bracketed ipv6 without port", "[2001:db8::1]", "2001:db8::1", ""},
+		{"bare ipv6", "2001:db8::1", "2001:db8::1", ""},
+		{"bare ipv6 ending with a number group", "2001:db8::1:9100", "2001:db8::1:9100", ""},
+		{"ipv6 loopback", "::1", "::1", ""},
+		{"ipv6 with zone and port", "[fe80::1%eth0]:9100", "fe80::1%eth0", "9100"},
+		{"bare ipv6 with zone", "fe80::1%eth0", "fe80::1%eth0", ""},
+		{"ipv4-mapped ipv6", "[::ffff:10.0.0.1]:20160", "::ffff:10.0.0.1", "20160"},
+		{"empty", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := SplitHostPort(tt.address)
+			if host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("SplitHostPort() = %v, %v, want %v, %v", host, port, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{"ipv4 with port", "10.0.0.1:20160", "10.0.0.1"},
+		{"ipv4 without port", "10.0.0.1", "10.0.0.1"},
+		{"fqdn with port", "TiKV-0.tikv-peer.svc.:20160", "tikv-0.tikv-peer.svc"},
+		{"hostname without port", "tikv-0", "tikv-0"},
+		{"bracketed ipv6 with port", "[2001:DB8:0:0::1]:20160", "2001:db8::1"},
+		{"bracketed ipv6 without port", "[2001:db8::1]", "2001:db8::1"},
+		{"bare ipv6", "2001:0db8:0000::0001", "2001:db8::1"},
+		{"ipv6 with zone", "[FE80::1%eth0]:9100", "fe80::1%eth0"},
+		{"ipv4-mapped ipv6", "[::ffff:10.0.0.1]:20160", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostOf(tt.address); got != tt.want {
+				t.Errorf("HostOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
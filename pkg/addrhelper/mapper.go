@@ -0,0 +1,126 @@
+package addrhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"context"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+const defaultResolveTimeout = 3 * time.Second
+
+// Mapper maps nodes (the instances reported by prometheus) to pd stores.
+// A store belongs to the node it is mapped to by Mapping; otherwise, a store belongs to a node if they share
+// the same normalized host, or any IP after resolving.
+type Mapper struct {
+	resolver Resolver
+	mapping  Mapping
+	timeout  time.Duration
+}
+
+// NewMapper creates a Mapper; mapping could be nil.
+func NewMapper(resolver Resolver, mapping Mapping) *Mapper {
+	return &Mapper{resolver: resolver, mapping: mapping, timeout: defaultResolveTimeout}
+}
+
+// Topology is the exact mapping between nodes and stores.
+type Topology struct {
+	storesOfNode map[string][]pdhelper.Store
+	nodeOfStore  map[uint]string
+}
+
+// StoresOf returns the stores on node.
+func (it *Topology) StoresOf(node string) []pdhelper.Store {
+	return it.storesOfNode[node]
+}
+
+// NodeOf returns the node of store, it is false if the store does not belong to any node.
+func (it *Topology) NodeOf(store pdhelper.Store) (string, bool) {
+	node, ok := it.nodeOfStore[store.Id]
+	return node, ok
+}
+
+// Map resolves nodes and the hosts of stores once, then matches them exactly.
+// The explicit Mapping is consulted at first; if a store is mapped to a node without metrics, it falls back to matching addresses.
+// If a store matches several nodes, the one with the same host is preferred, otherwise the first one in order.
+func (it *Mapper) Map(nodes []string, stores []pdhelper.Store) *Topology {
+	ctx, cancel := context.WithTimeout(context.Background(), it.timeout)
+	defer cancel()
+	cache := make(map[string][]string)
+
+	sortedNodes := append([]string(nil), nodes...)
+	sort.Strings(sortedNodes)
+	nodeIdentities := make(map[string][]string)
+	for _, node := range sortedNodes {
+		nodeIdentities[node] = it.identities(ctx, HostOf(node), cache)
+	}
+
+	result := &Topology{
+		storesOfNode: make(map[string][]pdhelper.Store),
+		nodeOfStore:  make(map[uint]string),
+	}
+	for _, store := range stores {
+		var matched string
+		for _, node := range sortedNodes {
+			if it.mapping.Matches(node, store) {
+				matched = node
+				break
+			}
+		}
+		if matched != "" {
+			result.storesOfNode[matched] = append(result.storesOfNode[matched], store)
+			result.nodeOfStore[store.Id] = matched
+			continue
+		}
+
+		host := HostOf(store.Address)
+		storeIdentities := it.identities(ctx, host, cache)
+		for _, node := range sortedNodes {
+			if HostOf(node) == host {
+				matched = node
+				break
+			}
+			if matched == "" && intersects(nodeIdentities[node], storeIdentities) {
+				matched = node
+			}
+		}
+		if matched == "" {
+			continue
+		}
+		result.storesOfNode[matched] = append(result.storesOfNode[matched], store)
+		result.nodeOfStore[store.Id] = matched
+	}
+	return result
+}
+
+// identities returns the host itself with its resolved IPs.
+func (it *Mapper) identities(ctx context.Context, host string, cache map[string][]string) []string {
+	if cached, ok := cache[host]; ok {
+		return cached
+	}
+	result := []string{host}
+	if !isIP(host) && host != "" {
+		addrs, err := it.resolver.LookupHost(ctx, host)
+		if err != nil {
+			log.L().With(zap.Error(err)).With(zap.String("host", host)).Debug("failed to resolve host, match it by name only")
+		}
+		for _, addr := range addrs {
+			result = append(result, NormalizeHost(addr))
+		}
+	}
+	cache[host] = result
+	return result
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
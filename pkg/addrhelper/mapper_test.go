@@ -0,0 +1,115 @@
+package addrhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"reflect"
+	"testing"
+)
+
+func storeIds(stores []pdhelper.Store) []uint {
+	var result []uint
+	for _, store := range stores {
+		result = append(result, store.Id)
+	}
+	return result
+}
+
+func TestMapper_Map(t *testing.T) {
+	resolver := StaticResolver{
+		"tikv-0.tikv-peer.tidb.svc": {"10.0.1.1"},
+		"tikv-1.tikv-peer.tidb.svc": {"10.0.1.2"},
+		"host-a":                    {"10.0.2.1"},
+		"host-v6":                   {"2001:db8::9"},
+	}
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "10.0.0.1:20160"},
+		{Id: 11, Address: "10.0.0.11:20160"},
+		{Id: 100, Address: "10.0.0.100:20160"},
+		{Id: 2, Address: "10.0.0.1:20161"},
+		{Id: 3, Address: "tikv-0.tikv-peer.tidb.svc:20160"},
+		{Id: 4, Address: "TIKV-1.tikv-peer.tidb.svc.:20160"},
+		{Id: 5, Address: "10.0.2.1:20160"},
+		{Id: 6, Address: "unresolvable:20160"},
+		{Id: 7, Address: "[2001:db8::7]:20160"},
+		{Id: 8, Address: "[2001:db8::70]:20160"},
+		{Id: 9, Address: "[2001:db8::9]:20161"},
+	}
+	tests := []struct {
+		name    string
+		node    string
+		wantIds []uint
+	}{
+		{
+			name:    "ip does not match ips with the same prefix",
+			node:    "10.0.0.1",
+			wantIds: []uint{1, 2},
+		}, {
+			name:    "ip with port",
+			node:    "10.0.0.11:9100",
+			wantIds: []uint{11},
+		}, {
+			name:    "ip of a store with hostname",
+			node:    "10.0.1.1",
+			wantIds: []uint{3},
+		}, {
+			name:    "fully qualified hostname in different case",
+			node:    "tikv-1.tikv-peer.tidb.svc",
+			wantIds: []uint{4},
+		}, {
+			name:    "hostname of a store with ip",
+			node:    "host-a",
+			wantIds: []uint{5},
+		}, {
+			name:    "bare ipv6 does not match ipv6 with the same prefix",
+			node:    "2001:db8::7",
+			wantIds: []uint{7},
+		}, {
+			name:    "bracketed ipv6 in a different form",
+			node:    "[2001:DB8:0::70]",
+			wantIds: []uint{8},
+		}, {
+			name:    "hostname resolved to ipv6",
+			node:    "host-v6",
+			wantIds: []uint{9},
+		}, {
+			name:    "unknown node",
+			node:    "10.0.0.10",
+			wantIds: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topology := NewMapper(resolver, nil).Map([]string{tt.node}, stores)
+			if got := storeIds(topology.StoresOf(tt.node)); !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("StoresOf() = %v, want %v", got, tt.wantIds)
+			}
+			for _, store := range stores {
+				node, ok := topology.NodeOf(store)
+				if want := contains(tt.wantIds, store.Id); ok != want || (ok && node != tt.node) {
+					t.Errorf("NodeOf(%d) = %v, %v", store.Id, node, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestMapper_MapPrefersSameHost(t *testing.T) {
+	resolver := StaticResolver{"host-a": {"10.0.2.1"}}
+	stores := []pdhelper.Store{{Id: 1, Address: "host-a:20160"}}
+	topology := NewMapper(resolver, nil).Map([]string{"10.0.2.1", "host-a"}, stores)
+	if node, _ := topology.NodeOf(stores[0]); node != "host-a" {
+		t.Errorf("NodeOf() = %v, want host-a", node)
+	}
+	if got := topology.StoresOf("10.0.2.1"); len(got) != 0 {
+		t.Errorf("StoresOf() = %v, want empty", got)
+	}
+}
+
+func contains(ids []uint, id uint) bool {
+	for _, item := range ids {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package addrhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The formats of mapping files.
+const (
+	MappingStatic  = "static"
+	MappingTiUP    = "tiup"
+	MappingAnsible = "ansible"
+)
+
+const defaultTiKVPort = 20160
+
+// Mapping maps probe endpoints (the hosts in instance and ping labels) to stores explicitly,
+// it is consulted before matching addresses.
+type Mapping map[string]MappingEntry
+
+// MappingEntry refers stores by ids or by the addresses advertised to pd.
+type MappingEntry struct {
+	StoreIds       []uint   `yaml:"store-ids"`
+	StoreAddresses []string `yaml:"store-addresses"`
+}
+
+// Matches reports whether store is mapped to node.
+func (it Mapping) Matches(node string, store pdhelper.Store) bool {
+	entry, ok := it[HostOf(node)]
+	if !ok {
+		return false
+	}
+	for _, id := range entry.StoreIds {
+		if id == store.Id {
+			return true
+		}
+	}
+	for _, address := range entry.StoreAddresses {
+		if sameAddress(address, store.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+func (it Mapping) add(endpoint string, entry MappingEntry) {
+	key := HostOf(endpoint)
+	existed := it[key]
+	existed.StoreIds = append(existed.StoreIds, entry.StoreIds...)
+	existed.StoreAddresses = append(existed.StoreAddresses, entry.StoreAddresses...)
+	it[key] = existed
+}
+
+// LoadMapping loads the mapping from file in format: static, tiup or ansible.
+func LoadMapping(path, format string) (Mapping, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case MappingStatic, "":
+		return ParseStaticMapping(content)
+	case MappingTiUP:
+		return ParseTiUPTopology(content)
+	case MappingAnsible:
+		return ParseAnsibleInventory(content)
+	default:
+		return nil, fmt.Errorf("unsupported mapping format %s", format)
+	}
+}
+
+// ParseStaticMapping parses yaml like:
+//
+//	tikv-host-1:
+//	  store-ids: [1, 4]
+//	tikv-host-2:
+//	  store-addresses: ["basic-tikv-2.basic-tikv-peer.tidb-cluster.svc:20160"]
+func ParseStaticMapping(content []byte) (Mapping, error) {
+	var parsed map[string]MappingEntry
+	if err := yaml.UnmarshalStrict(content, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse static mapping: %v", err)
+	}
+	result := make(Mapping)
+	for endpoint, entry := range parsed {
+		result.add(endpoint, entry)
+	}
+	return result, nil
+}
+
+type tiupTopology struct {
+	TiKVServers []struct {
+		Host          string `yaml:"host"`
+		Port          int    `yaml:"port"`
+		AdvertiseAddr string `yaml:"advertise_addr"`
+	} `yaml:"tikv_servers"`
+}
+
+// ParseTiUPTopology maps the host of each item in tikv_servers of TiUP topology.yaml to its advertised address.
+func ParseTiUPTopology(content []byte) (Mapping, error) {
+	var topology tiupTopology
+	if err := yaml.Unmarshal(content, &topology); err != nil {
+		return nil, fmt.Errorf("failed to parse tiup topology: %v", err)
+	}
+	result := make(Mapping)
+	for _, server := range topology.TiKVServers {
+		if server.Host == "" {
+			return nil, fmt.Errorf("tikv server without host in tiup topology")
+		}
+		address := server.AdvertiseAddr
+		if address == "" {
+			port := server.Port
+			if port == 0 {
+				port = defaultTiKVPort
+			}
+			address = net.JoinHostPort(server.Host, strconv.Itoa(port))
+		}
+		result.add(server.Host, MappingEntry{StoreAddresses: []string{address}})
+	}
+	return result, nil
+}
+
+// ParseAnsibleInventory maps the hosts in [tikv_servers] of tidb-ansible inventory.ini to their addresses,
+// a host could be an alias with ansible_host, and tikv_port overrides the default port.
+func ParseAnsibleInventory(content []byte) (Mapping, error) {
+	result := make(Mapping)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[tikv_servers]"
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		fields := strings.Fields(line)
+		alias, host, port := fields[0], fields[0], strconv.Itoa(defaultTiKVPort)
+		for _, field := range fields[1:] {
+			if value := strings.TrimPrefix(field, "ansible_host="); value != field {
+				host = value
+			}
+			if value := strings.TrimPrefix(field, "tikv_port="); value != field {
+				port = value
+			}
+		}
+		entry := MappingEntry{StoreAddresses: []string{net.JoinHostPort(host, port)}}
+		result.add(host, entry)
+		if alias != host {
+			result.add(alias, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse ansible inventory: %v", err)
+	}
+	return result, nil
+}
+
+// sameAddress compares addresses by normalized hosts and ports.
+func sameAddress(a, b string) bool {
+	hostA, portA := SplitHostPort(a)
+	hostB, portB := SplitHostPort(b)
+	return NormalizeHost(hostA) == NormalizeHost(hostB) && portA == portB
+}
@@ -0,0 +1,135 @@
+package addrhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMapping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evictor-mapping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    Mapping
+		wantErr bool
+	}{
+		{
+			name:   "static",
+			format: MappingStatic,
+			content: `
+tikv-host-1:
+  store-ids: [1, 4]
+TiKV-Host-2.:
+  store-addresses: ["basic-tikv-2.basic-tikv-peer.tidb-cluster.svc:20160"]
+"[2001:db8::3]":
+  store-ids: [3]
+`,
+			want: Mapping{
+				"tikv-host-1": {StoreIds: []uint{1, 4}},
+				"tikv-host-2": {StoreAddresses: []string{"basic-tikv-2.basic-tikv-peer.tidb-cluster.svc:20160"}},
+				"2001:db8::3": {StoreIds: []uint{3}},
+			},
+		}, {
+			name:    "static with unknown field",
+			format:  MappingStatic,
+			content: "tikv-host-1:\n  stores: [1]\n",
+			wantErr: true,
+		}, {
+			name:   "tiup",
+			format: MappingTiUP,
+			content: `
+global:
+  user: tidb
+pd_servers:
+  - host: 10.0.1.1
+tikv_servers:
+  - host: 10.0.1.2
+  - host: tikv-host-3
+    port: 20161
+  - host: 10.0.1.4
+    advertise_addr: basic-tikv-4.basic-tikv-peer.tidb-cluster.svc:20160
+  - host: 2001:db8::5
+`,
+			want: Mapping{
+				"10.0.1.2":    {StoreAddresses: []string{"10.0.1.2:20160"}},
+				"tikv-host-3": {StoreAddresses: []string{"tikv-host-3:20161"}},
+				"10.0.1.4":    {StoreAddresses: []string{"basic-tikv-4.basic-tikv-peer.tidb-cluster.svc:20160"}},
+				"2001:db8::5": {StoreAddresses: []string{"[2001:db8::5]:20160"}},
+			},
+		}, {
+			name:   "ansible",
+			format: MappingAnsible,
+			content: `
+[pd_servers]
+10.0.1.1
+
+[tikv_servers]
+# comment
+TiKV1-1 ansible_host=10.0.1.2 deploy_dir=/data1/deploy tikv_port=20171 labels="host=tikv1"
+TiKV1-2 ansible_host=10.0.1.2 deploy_dir=/data2/deploy tikv_port=20172 labels="host=tikv1"
+10.0.1.3
+
+[monitoring_servers]
+10.0.1.4
+`,
+			want: Mapping{
+				"10.0.1.2": {StoreAddresses: []string{"10.0.1.2:20171", "10.0.1.2:20172"}},
+				"tikv1-1":  {StoreAddresses: []string{"10.0.1.2:20171"}},
+				"tikv1-2":  {StoreAddresses: []string{"10.0.1.2:20172"}},
+				"10.0.1.3": {StoreAddresses: []string{"10.0.1.3:20160"}},
+			},
+		}, {
+			name:    "unsupported format",
+			format:  "unknown",
+			content: "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadMapping(path, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadMapping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapper_MapWithMapping(t *testing.T) {
+	mapping := Mapping{
+		"probe-1": {StoreIds: []uint{1}},
+		"probe-2": {StoreAddresses: []string{"basic-tikv-2.basic-tikv-peer.tidb-cluster.svc:20160"}},
+		"probe-9": {StoreIds: []uint{3}},
+	}
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "basic-tikv-1.basic-tikv-peer.tidb-cluster.svc:20160"},
+		{Id: 2, Address: "basic-tikv-2.basic-tikv-peer.tidb-cluster.svc:20160"},
+		{Id: 3, Address: "10.0.0.3:20160"},
+	}
+	// there are no metrics of probe-9, so store 3 falls back to matching addresses
+	topology := NewMapper(StaticResolver{}, mapping).Map([]string{"probe-1:9100", "probe-2", "10.0.0.3"}, stores)
+
+	want := map[uint]string{1: "probe-1:9100", 2: "probe-2", 3: "10.0.0.3"}
+	for _, store := range stores {
+		if node, ok := topology.NodeOf(store); !ok || node != want[store.Id] {
+			t.Errorf("NodeOf(%d) = %v, %v, want %v", store.Id, node, ok, want[store.Id])
+		}
+	}
+}
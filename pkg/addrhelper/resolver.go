@@ -0,0 +1,21 @@
+package addrhelper
+
+import (
+	"context"
+	"fmt"
+)
+
+// Resolver resolves hostnames to IPs; net.DefaultResolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// StaticResolver resolves hostnames with a fixed table, hostnames out of the table are not resolvable.
+type StaticResolver map[string][]string
+
+func (it StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := it[host]; ok {
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("no such host %s", host)
+}
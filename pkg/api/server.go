@@ -0,0 +1,79 @@
+package api
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/evictor"
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"context"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// StatusProvider is implemented by evictor.Evictor.
+type StatusProvider interface {
+	Status() evictor.Status
+}
+
+// Status is evictor.Status with the leadership of this replica.
+type Status struct {
+	Leader bool `json:"leader"`
+	evictor.Status
+}
+
+// NewHandler serves the status of evictor under /status and the metrics of evictor itself under /metrics;
+// isLeader could be nil when leader election is disabled.
+func NewHandler(provider StatusProvider, isLeader func() bool, gatherer prometheus.Gatherer) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		leader := true
+		if isLeader != nil {
+			leader = isLeader()
+		}
+		writeJSON(w, Status{Leader: leader, Status: provider.Status()})
+	})
+	mux.HandleFunc("/status/nodes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, provider.Status().Nodes)
+	})
+	mux.HandleFunc("/status/links", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, provider.Status().Links)
+	})
+	mux.HandleFunc("/status/suspicions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, provider.Status().Suspicions)
+	})
+	mux.HandleFunc("/status/evicted", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, provider.Status().Evicted)
+	})
+	mux.HandleFunc("/status/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, provider.Status().Config)
+	})
+	return mux
+}
+
+// Serve serves handler on addr until ctx is done.
+func Serve(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	log.L().With(zap.String("address", addr)).Info("serving status api and metrics")
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.L().With(zap.Error(err)).Warn("failed to write response")
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/evictor"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	status evictor.Status
+}
+
+func (it *fakeProvider) Status() evictor.Status {
+	return it.status
+}
+
+func TestNewHandler(t *testing.T) {
+	evictedAt := time.Date(2020, 11, 17, 8, 0, 0, 0, time.UTC)
+	provider := &fakeProvider{status: evictor.Status{
+		UpdatedAt: evictedAt,
+		Nodes:     map[string]evictor.NodeHealth{"10.0.0.1": evictor.Healthy, "10.0.0.2": evictor.Unhealthy},
+		Links:     []evictor.LinkStatus{{From: "10.0.0.2", To: "10.0.0.1", State: evictor.LinkBad}},
+		Evicted: []evictor.EvictedStatus{{
+			Store:     pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"},
+			Action:    evictor.ActionEvictLeader,
+			Owned:     true,
+			Reason:    "node 10.0.0.2 is unhealthy",
+			EvictedAt: &evictedAt,
+			Evictions: 1,
+		}},
+		Config: evictor.Config{MaxEvicted: 2, Threshold: time.Second},
+	}}
+	handler := NewHandler(provider, func() bool { return false }, prometheus.NewRegistry())
+
+	tests := []struct {
+		name   string
+		path   string
+		result interface{}
+		want   interface{}
+	}{
+		{
+			name:   "status",
+			path:   "/status",
+			result: &Status{},
+			want:   &Status{Leader: false, Status: provider.status},
+		}, {
+			name:   "nodes",
+			path:   "/status/nodes",
+			result: &map[string]evictor.NodeHealth{},
+			want:   &provider.status.Nodes,
+		}, {
+			name:   "links",
+			path:   "/status/links",
+			result: &[]evictor.LinkStatus{},
+			want:   &provider.status.Links,
+		}, {
+			name:   "evicted",
+			path:   "/status/evicted",
+			result: &[]evictor.EvictedStatus{},
+			want:   &provider.status.Evicted,
+		}, {
+			name:   "config",
+			path:   "/status/config",
+			result: &evictor.Config{},
+			want:   &provider.status.Config,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want %d", tt.path, recorder.Code, http.StatusOK)
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), tt.result); err != nil {
+				t.Fatalf("GET %s returns invalid json: %v", tt.path, err)
+			}
+			if !reflect.DeepEqual(tt.result, tt.want) {
+				t.Errorf("GET %s = %+v, want %+v", tt.path, tt.result, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHandler_Metrics(t *testing.T) {
+	metrics := evictor.NewMetrics()
+	metrics.Failures.WithLabelValues(evictor.FailureEvict).Inc()
+	handler := NewHandler(&fakeProvider{}, nil, metrics.Registry)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	for _, want := range []string{`evictor_failures_total{reason="evict"} 1`, "evictor_leader 1"} {
+		if !strings.Contains(recorder.Body.String(), want) {
+			t.Errorf("GET /metrics should contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package election
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper/etcdtest"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evictor-election")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := etcdtest.NewServer()
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		// newLock creates a lock per replica, which shares the same backend
+		newLock func() Lock
+		// expire makes the lock of all replicas expired
+		expire func()
+	}{
+		{
+			name:    "file",
+			newLock: func() Lock { return NewFileLock(filepath.Join(dir, "evictor.lock")) },
+			expire:  func() { time.Sleep(1100 * time.Millisecond) },
+		},
+		{
+			name:    "etcd",
+			newLock: func() Lock { return NewEtcdLock(etcdhelper.NewClient(server.URL), DefaultEtcdKey) },
+			expire:  server.ExpireLeases,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a, b := tt.newLock(), tt.newLock()
+
+			if held, err := a.TryAcquire(ctx, "a", time.Second); err != nil || !held {
+				t.Fatalf("a.TryAcquire() = %v, %v, want true", held, err)
+			}
+			if held, err := b.TryAcquire(ctx, "b", time.Second); err != nil || held {
+				t.Fatalf("b.TryAcquire() = %v, %v, want false", held, err)
+			}
+			if held, err := a.TryAcquire(ctx, "a", time.Second); err != nil || !held {
+				t.Fatalf("a.TryAcquire() renew = %v, %v, want true", held, err)
+			}
+
+			// a is partitioned for longer than ttl
+			tt.expire()
+			if held, err := b.TryAcquire(ctx, "b", time.Second); err != nil || !held {
+				t.Fatalf("b.TryAcquire() after expired = %v, %v, want true", held, err)
+			}
+			if held, err := a.TryAcquire(ctx, "a", time.Second); err != nil || held {
+				t.Fatalf("a.TryAcquire() after b acquired = %v, %v, want false", held, err)
+			}
+
+			if err := b.Release(ctx, "b"); err != nil {
+				t.Fatalf("b.Release() error = %v", err)
+			}
+			if held, err := a.TryAcquire(ctx, "a", time.Second); err != nil || !held {
+				t.Fatalf("a.TryAcquire() after released = %v, %v, want true", held, err)
+			}
+			if err := a.Release(ctx, "a"); err != nil {
+				t.Fatalf("a.Release() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestElector_Run(t *testing.T) {
+	server := etcdtest.NewServer()
+	defer server.Close()
+
+	var running int32
+	var maxRunning int32
+	run := func(ctx context.Context) error {
+		if current := atomic.AddInt32(&running, 1); current > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, current)
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	newElector := func(id string) *Elector {
+		return NewElector(NewEtcdLock(etcdhelper.NewClient(server.URL), DefaultEtcdKey), id, 300*time.Millisecond)
+	}
+	first, second := newElector("first"), newElector("second")
+
+	firstCtx, firstCancel := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		_ = first.Run(firstCtx, run)
+		close(firstDone)
+	}()
+	waitFor(t, first.IsLeader)
+
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	defer secondCancel()
+	go func() { _ = second.Run(secondCtx, run) }()
+	time.Sleep(300 * time.Millisecond)
+	if second.IsLeader() {
+		t.Fatalf("second should not be leader while first holds the lock")
+	}
+
+	// first exits and releases the lock, then second takes over
+	firstCancel()
+	<-firstDone
+	waitFor(t, second.IsLeader)
+	if first.IsLeader() || first.Transitions() != 2 {
+		t.Errorf("first IsLeader() = %v, Transitions() = %d, want false, 2", first.IsLeader(), first.Transitions())
+	}
+	if got := atomic.LoadInt32(&maxRunning); got != 1 {
+		t.Errorf("max running leaders = %d, want 1", got)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition is not satisfied in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
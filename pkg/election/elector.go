@@ -0,0 +1,102 @@
+package election
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Elector runs a function only while this replica holds the Lock.
+type Elector struct {
+	lock Lock
+	id   string
+	ttl  time.Duration
+	// OnChange is called with the new leadership after it changes; optional.
+	OnChange func(leader bool)
+
+	sync.Mutex
+	leader      bool
+	transitions uint64
+}
+
+func NewElector(lock Lock, id string, ttl time.Duration) *Elector {
+	return &Elector{lock: lock, id: id, ttl: ttl}
+}
+
+func (it *Elector) IsLeader() bool {
+	it.Lock()
+	defer it.Unlock()
+	return it.leader
+}
+
+// Transitions returns how many times the leadership of this replica has changed.
+func (it *Elector) Transitions() uint64 {
+	it.Lock()
+	defer it.Unlock()
+	return it.transitions
+}
+
+func (it *Elector) setLeader(leader bool) {
+	it.Lock()
+	it.leader = leader
+	it.transitions++
+	it.Unlock()
+	if it.OnChange != nil {
+		it.OnChange(leader)
+	}
+}
+
+// Run tries to acquire the lock every third of ttl, runs fn with a context once it becomes the leader,
+// and cancels that context once it loses the lock or fails to renew it. It returns after ctx is done.
+func (it *Elector) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	ticker := time.NewTicker(it.ttl / 3)
+	defer ticker.Stop()
+
+	var cancel context.CancelFunc
+	var done chan struct{}
+	stepDown := func() {
+		if cancel == nil {
+			return
+		}
+		cancel()
+		<-done
+		cancel = nil
+		it.setLeader(false)
+	}
+
+	for {
+		held, err := it.lock.TryAcquire(ctx, it.id, it.ttl)
+		if err != nil && ctx.Err() == nil {
+			log.L().With(zap.Error(err)).With(zap.String("id", it.id)).Warn("failed to acquire leadership")
+		}
+		if held && cancel == nil {
+			log.L().With(zap.String("id", it.id)).Info("became leader, start evicting")
+			leaderCtx, leaderCancel := context.WithCancel(ctx)
+			cancel, done = leaderCancel, make(chan struct{})
+			it.setLeader(true)
+			go func() {
+				defer close(done)
+				if err := fn(leaderCtx); err != nil {
+					log.L().With(zap.Error(err)).Error("failed to execute as leader")
+				}
+			}()
+		} else if !held && cancel != nil {
+			log.L().With(zap.String("id", it.id)).Warn("lost leadership, stop evicting")
+			stepDown()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			stepDown()
+			releaseCtx, releaseCancel := context.WithTimeout(context.Background(), it.ttl/3)
+			defer releaseCancel()
+			if err := it.lock.Release(releaseCtx, it.id); err != nil {
+				log.L().With(zap.Error(err)).With(zap.String("id", it.id)).Warn("failed to release leadership")
+			}
+			return nil
+		}
+	}
+}
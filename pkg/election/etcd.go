@@ -0,0 +1,64 @@
+package election
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultEtcdKey = "/auto-failover-tikv-leader-evict/leader"
+
+// EtcdLock keeps the holder of the lock as a key attached to a lease in etcd, like the one embedded in PD.
+// The key is deleted by etcd once the lease expires, so others could acquire the lock.
+type EtcdLock struct {
+	client *etcdhelper.Client
+	key    string
+	sync.Mutex
+	lease int64
+}
+
+func NewEtcdLock(client *etcdhelper.Client, key string) *EtcdLock {
+	return &EtcdLock{client: client, key: key}
+}
+
+func (it *EtcdLock) TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	it.Lock()
+	defer it.Unlock()
+	if it.lease != 0 {
+		alive, err := it.client.KeepAlive(ctx, it.lease)
+		if err != nil {
+			return false, err
+		}
+		if !alive {
+			it.lease = 0
+		}
+	}
+	if it.lease == 0 {
+		lease, err := it.client.GrantLease(ctx, ttl)
+		if err != nil {
+			return false, err
+		}
+		it.lease = lease
+	}
+	succeeded, current, err := it.client.PutIfAbsent(ctx, it.key, id, it.lease)
+	if err != nil {
+		return false, err
+	}
+	if succeeded {
+		return true, nil
+	}
+	return current.Value == id && current.Lease == it.lease, nil
+}
+
+func (it *EtcdLock) Release(ctx context.Context, id string) error {
+	it.Lock()
+	defer it.Unlock()
+	if it.lease == 0 {
+		return nil
+	}
+	lease := it.lease
+	it.lease = 0
+	// revoking the lease deletes the key held by it
+	return it.client.Revoke(ctx, lease)
+}
@@ -0,0 +1,105 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const guardRetries = 10
+const guardStaleAfter = 10 * time.Second
+
+// FileLock keeps the holder of the lock in a local file, so it only works for replicas sharing the filesystem.
+// It is intended for tests and single-host deployments.
+type FileLock struct {
+	Path string
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{Path: path}
+}
+
+type fileLockContent struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (it *FileLock) TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := it.guarded(ctx, func() error {
+		current, err := it.read()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if current.Holder != "" && current.Holder != id && now.Before(current.ExpiresAt) {
+			return nil
+		}
+		acquired = true
+		return it.write(fileLockContent{Holder: id, ExpiresAt: now.Add(ttl)})
+	})
+	return acquired, err
+}
+
+func (it *FileLock) Release(ctx context.Context, id string) error {
+	return it.guarded(ctx, func() error {
+		current, err := it.read()
+		if err != nil || current.Holder != id {
+			return err
+		}
+		return os.Remove(it.Path)
+	})
+}
+
+func (it *FileLock) read() (fileLockContent, error) {
+	var result fileLockContent
+	content, err := ioutil.ReadFile(it.Path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (it *FileLock) write(content fileLockContent) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(it.Path, data, 0644)
+}
+
+// guarded runs fn while holding an exclusively created guard file, so read-modify-write of the lock file is atomic.
+func (it *FileLock) guarded(ctx context.Context, fn func() error) error {
+	guard := it.Path + ".guard"
+	for i := 0; i < guardRetries; i++ {
+		file, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			defer os.Remove(guard)
+			return fn()
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		// the guard might be left by a crashed replica
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > guardStaleAfter {
+			os.Remove(guard)
+			continue
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("failed to lock %s", guard)
+}
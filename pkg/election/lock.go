@@ -0,0 +1,15 @@
+package election
+
+import (
+	"context"
+	"time"
+)
+
+// Lock is a lease-based lock shared by evictor replicas.
+type Lock interface {
+	// TryAcquire acquires the lock for id, or renews it if id is holding it already.
+	// It returns false if the lock is held by others.
+	TryAcquire(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// Release gives up the lock if it is held by id.
+	Release(ctx context.Context, id string) error
+}
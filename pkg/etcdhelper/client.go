@@ -0,0 +1,214 @@
+package etcdhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultHTTPTimeout = 5 * time.Second
+
+// Client talks to the grpc-gateway of etcd, like the one embedded in PD, with json over http,
+// so evictor does not depend on the etcd client.
+type Client struct {
+	Endpoint string
+	client   *http.Client
+}
+
+func NewClient(endpoint string) *Client {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+	return &Client{
+		Endpoint: endpoint,
+		client:   &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// KeyValue is a key with its value, which are decoded from base64.
+type KeyValue struct {
+	Key            string
+	Value          string
+	Lease          int64
+	CreateRevision int64
+}
+
+type keyValue struct {
+	Key            string `json:"key"`
+	Value          string `json:"value,omitempty"`
+	Lease          int64  `json:"lease,string,omitempty"`
+	CreateRevision int64  `json:"create_revision,string,omitempty"`
+}
+
+func (it keyValue) decode() (KeyValue, error) {
+	key, err := base64.StdEncoding.DecodeString(it.Key)
+	if err != nil {
+		return KeyValue{}, err
+	}
+	value, err := base64.StdEncoding.DecodeString(it.Value)
+	if err != nil {
+		return KeyValue{}, err
+	}
+	return KeyValue{Key: string(key), Value: string(value), Lease: it.Lease, CreateRevision: it.CreateRevision}, nil
+}
+
+type rangeResponse struct {
+	Kvs []keyValue `json:"kvs"`
+}
+
+type compare struct {
+	Key            string `json:"key"`
+	Result         string `json:"result"`
+	Target         string `json:"target"`
+	CreateRevision int64  `json:"create_revision,string"`
+}
+
+type requestOp struct {
+	RequestPut   *keyValue `json:"request_put,omitempty"`
+	RequestRange *keyValue `json:"request_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []compare   `json:"compare"`
+	Success []requestOp `json:"success"`
+	Failure []requestOp `json:"failure"`
+}
+
+type responseOp struct {
+	ResponseRange *rangeResponse `json:"response_range,omitempty"`
+}
+
+type txnResponse struct {
+	Succeeded bool         `json:"succeeded"`
+	Responses []responseOp `json:"responses"`
+}
+
+type leaseRequest struct {
+	ID  int64 `json:"ID,string,omitempty"`
+	TTL int64 `json:"TTL,string,omitempty"`
+}
+
+type leaseResponse struct {
+	ID  int64 `json:"ID,string"`
+	TTL int64 `json:"TTL,string"`
+}
+
+type keepAliveResponse struct {
+	Result leaseResponse `json:"result"`
+}
+
+func encode(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// Get returns the value of key; found is false if the key does not exist.
+func (it *Client) Get(ctx context.Context, key string) (value KeyValue, found bool, err error) {
+	var response rangeResponse
+	if err := it.do(ctx, "/v3/kv/range", keyValue{Key: encode(key)}, &response); err != nil {
+		return KeyValue{}, false, err
+	}
+	if len(response.Kvs) == 0 {
+		return KeyValue{}, false, nil
+	}
+	value, err = response.Kvs[0].decode()
+	return value, err == nil, err
+}
+
+// Put sets the value of key, attached to lease if it is not 0.
+func (it *Client) Put(ctx context.Context, key, value string, lease int64) error {
+	return it.do(ctx, "/v3/kv/put", keyValue{Key: encode(key), Value: encode(value), Lease: lease}, nil)
+}
+
+// PutIfAbsent sets the value of key attached to lease only if the key does not exist.
+// It returns the current value of key if it exists.
+func (it *Client) PutIfAbsent(ctx context.Context, key, value string, lease int64) (bool, KeyValue, error) {
+	var response txnResponse
+	err := it.do(ctx, "/v3/kv/txn", txnRequest{
+		Compare: []compare{{Key: encode(key), Result: "EQUAL", Target: "CREATE", CreateRevision: 0}},
+		Success: []requestOp{{RequestPut: &keyValue{Key: encode(key), Value: encode(value), Lease: lease}}},
+		Failure: []requestOp{{RequestRange: &keyValue{Key: encode(key)}}},
+	}, &response)
+	if err != nil {
+		return false, KeyValue{}, err
+	}
+	if response.Succeeded {
+		return true, KeyValue{}, nil
+	}
+	for _, item := range response.Responses {
+		if item.ResponseRange != nil && len(item.ResponseRange.Kvs) > 0 {
+			current, err := item.ResponseRange.Kvs[0].decode()
+			return false, current, err
+		}
+	}
+	return false, KeyValue{}, nil
+}
+
+// GrantLease creates a lease which expires after ttl.
+func (it *Client) GrantLease(ctx context.Context, ttl time.Duration) (int64, error) {
+	seconds := int64(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	var response leaseResponse
+	if err := it.do(ctx, "/v3/lease/grant", leaseRequest{TTL: seconds}, &response); err != nil {
+		return 0, err
+	}
+	if response.ID == 0 {
+		return 0, fmt.Errorf("etcd granted an empty lease")
+	}
+	return response.ID, nil
+}
+
+// KeepAlive renews the lease; it returns false if the lease has expired.
+func (it *Client) KeepAlive(ctx context.Context, lease int64) (bool, error) {
+	var response keepAliveResponse
+	if err := it.do(ctx, "/v3/lease/keepalive", leaseRequest{ID: lease}, &response); err != nil {
+		return false, err
+	}
+	return response.Result.TTL > 0, nil
+}
+
+// Revoke deletes the lease and all keys attached to it.
+func (it *Client) Revoke(ctx context.Context, lease int64) error {
+	return it.do(ctx, "/v3/lease/revoke", leaseRequest{ID: lease}, nil)
+}
+
+func (it *Client) do(ctx context.Context, path string, request interface{}, result interface{}) error {
+	url := it.Endpoint + path
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := it.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	log.L().With(zap.String("url", url)).With(zap.Int("status", resp.StatusCode)).With(zap.String("output", string(content))).Debug("etcd gateway")
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd responded %d for %s: %s", resp.StatusCode, url, strings.TrimSpace(string(content)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
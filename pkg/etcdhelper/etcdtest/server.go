@@ -0,0 +1,222 @@
+// Package etcdtest provides an in-memory stand-in of the etcd grpc-gateway for tests.
+package etcdtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value          string
+	lease          int64
+	createRevision int64
+}
+
+// Server implements the subset of etcd grpc-gateway used by etcdhelper.Client.
+// Keys and values are kept base64 encoded as they are on the wire.
+type Server struct {
+	*httptest.Server
+	sync.Mutex
+	revision  int64
+	nextLease int64
+	entries   map[string]entry
+	leases    map[int64]time.Time
+	ttls      map[int64]time.Duration
+}
+
+func NewServer() *Server {
+	result := &Server{
+		nextLease: 1000,
+		entries:   make(map[string]entry),
+		leases:    make(map[int64]time.Time),
+		ttls:      make(map[int64]time.Duration),
+	}
+	result.Server = httptest.NewServer(http.HandlerFunc(result.serve))
+	return result
+}
+
+// ExpireLeases expires all leases immediately, like the holder is partitioned for longer than ttl.
+func (it *Server) ExpireLeases() {
+	it.Lock()
+	defer it.Unlock()
+	for id := range it.leases {
+		it.revoke(id)
+	}
+}
+
+func (it *Server) revoke(id int64) {
+	delete(it.leases, id)
+	delete(it.ttls, id)
+	for key, item := range it.entries {
+		if item.lease == id {
+			delete(it.entries, key)
+		}
+	}
+}
+
+func (it *Server) expire() {
+	now := time.Now()
+	for id, expiresAt := range it.leases {
+		if now.After(expiresAt) {
+			it.revoke(id)
+		}
+	}
+}
+
+type keyValue struct {
+	Key            string `json:"key"`
+	Value          string `json:"value,omitempty"`
+	Lease          string `json:"lease,omitempty"`
+	CreateRevision string `json:"create_revision,omitempty"`
+}
+
+type requestOp struct {
+	RequestPut   *keyValue `json:"request_put,omitempty"`
+	RequestRange *keyValue `json:"request_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []keyValue  `json:"compare"`
+	Success []requestOp `json:"success"`
+	Failure []requestOp `json:"failure"`
+}
+
+type leaseRequest struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+func (it *Server) rangeOf(key string) map[string]interface{} {
+	item, ok := it.entries[key]
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"kvs": []keyValue{{
+			Key:            key,
+			Value:          item.value,
+			Lease:          strconv.FormatInt(item.lease, 10),
+			CreateRevision: strconv.FormatInt(item.createRevision, 10),
+		}},
+		"count": "1",
+	}
+}
+
+func (it *Server) put(request keyValue) bool {
+	lease, _ := strconv.ParseInt(request.Lease, 10, 64)
+	if _, ok := it.leases[lease]; lease != 0 && !ok {
+		return false
+	}
+	it.revision++
+	item, ok := it.entries[request.Key]
+	if !ok {
+		item.createRevision = it.revision
+	}
+	item.value = request.Value
+	item.lease = lease
+	it.entries[request.Key] = item
+	return true
+}
+
+func (it *Server) serve(w http.ResponseWriter, r *http.Request) {
+	it.Lock()
+	defer it.Unlock()
+	it.expire()
+	var response interface{}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var request keyValue
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response = it.rangeOf(request.Key)
+	case "/v3/kv/put":
+		var request keyValue
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !it.put(request) {
+			http.Error(w, `{"error":"etcdserver: requested lease not found"}`, http.StatusNotFound)
+			return
+		}
+		response = map[string]interface{}{}
+	case "/v3/kv/txn":
+		var request txnRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// only "create_revision of key EQUAL 0" is supported
+		succeeded := true
+		for _, item := range request.Compare {
+			if _, ok := it.entries[item.Key]; ok {
+				succeeded = false
+			}
+		}
+		ops := request.Failure
+		if succeeded {
+			ops = request.Success
+		}
+		var responses []map[string]interface{}
+		for _, op := range ops {
+			if op.RequestPut != nil {
+				if !it.put(*op.RequestPut) {
+					http.Error(w, `{"error":"etcdserver: requested lease not found"}`, http.StatusNotFound)
+					return
+				}
+				responses = append(responses, map[string]interface{}{"response_put": map[string]interface{}{}})
+			}
+			if op.RequestRange != nil {
+				responses = append(responses, map[string]interface{}{"response_range": it.rangeOf(op.RequestRange.Key)})
+			}
+		}
+		result := map[string]interface{}{"responses": responses}
+		if succeeded {
+			result["succeeded"] = true
+		}
+		response = result
+	case "/v3/lease/grant":
+		var request leaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		seconds, _ := strconv.ParseInt(request.TTL, 10, 64)
+		it.nextLease++
+		it.ttls[it.nextLease] = time.Duration(seconds) * time.Second
+		it.leases[it.nextLease] = time.Now().Add(it.ttls[it.nextLease])
+		response = map[string]string{"ID": strconv.FormatInt(it.nextLease, 10), "TTL": request.TTL}
+	case "/v3/lease/keepalive":
+		var request leaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, _ := strconv.ParseInt(request.ID, 10, 64)
+		result := map[string]string{"ID": request.ID}
+		if _, ok := it.leases[id]; ok {
+			it.leases[id] = time.Now().Add(it.ttls[id])
+			result["TTL"] = strconv.FormatInt(int64(it.ttls[id]/time.Second), 10)
+		}
+		response = map[string]interface{}{"result": result}
+	case "/v3/lease/revoke":
+		var request leaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, _ := strconv.ParseInt(request.ID, 10, 64)
+		it.revoke(id)
+		response = map[string]interface{}{}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,140 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/state"
+	"fmt"
+)
+
+const ActionEvictLeader string = "evict-leader"
+const ActionEvictSlowStore string = "evict-slow-store"
+
+// Action mitigates an Unhealthy store, and could be reverted once the store becomes Healthy.
+type Action interface {
+	Name() string
+	Apply(pd pdhelper.Executor, store pdhelper.Store) error
+	Revert(pd pdhelper.Executor, store pdhelper.Store) error
+	// ListApplied returns the stores which are mitigated by this action currently.
+	ListApplied(pd pdhelper.Executor) ([]pdhelper.Store, error)
+}
+
+// mitigatedStore is a store with the name of the action applied on it.
+type mitigatedStore struct {
+	pdhelper.Store
+	Action string `json:"action"`
+}
+
+// newActions creates all actions, the stores mitigated by evict-slow-store are restored from current state.
+func newActions(current *state.State) map[string]Action {
+	slowStore := newEvictSlowStoreAction(current)
+	for _, store := range current.Owned(ActionEvictSlowStore) {
+		slowStore.mitigated[store.Id] = store
+	}
+	return map[string]Action{
+		ActionEvictLeader:    &evictLeaderAction{},
+		ActionEvictSlowStore: slowStore,
+	}
+}
+
+// evictLeaderAction adds an evict-leader-scheduler for the store.
+type evictLeaderAction struct{}
+
+func (it *evictLeaderAction) Name() string {
+	return ActionEvictLeader
+}
+
+func (it *evictLeaderAction) Apply(pd pdhelper.Executor, store pdhelper.Store) error {
+	return pd.AddEvictScheduler(store.Id)
+}
+
+func (it *evictLeaderAction) Revert(pd pdhelper.Executor, store pdhelper.Store) error {
+	return pd.RemoveEvictScheduler(store.Id)
+}
+
+func (it *evictLeaderAction) ListApplied(pd pdhelper.Executor) ([]pdhelper.Store, error) {
+	return pd.ListEvictedStore()
+}
+
+// slowStoreNote tells that a store mitigated by evict-slow-store is only marked by evictor, PD evicts its leaders by
+// its own slow score, although the store counts toward MaxEvicted.
+const slowStoreNote = "pd evicts leaders only if its own slow score of the store is high"
+
+// evictSlowStoreAction hands the store over to PD's evict-slow-store-scheduler, which tracks slow scores by itself.
+// The scheduler is cluster-wide, so it is added with the first mitigated store and removed with the last one, only
+// if it is added by evictor rather than operators.
+type evictSlowStoreAction struct {
+	mitigated map[uint]pdhelper.Store
+	state     *state.State
+}
+
+func newEvictSlowStoreAction(current *state.State) *evictSlowStoreAction {
+	return &evictSlowStoreAction{mitigated: make(map[uint]pdhelper.Store), state: current}
+}
+
+func (it *evictSlowStoreAction) Name() string {
+	return ActionEvictSlowStore
+}
+
+func (it *evictSlowStoreAction) Apply(pd pdhelper.Executor, store pdhelper.Store) error {
+	existed, err := pd.HasEvictSlowStoreScheduler()
+	if err != nil {
+		return err
+	}
+	if !existed {
+		if err := pd.AddEvictSlowStoreScheduler(); err != nil {
+			return err
+		}
+		it.state.SlowStoreSchedulerCreated = true
+	}
+	it.mitigated[store.Id] = store
+	return nil
+}
+
+func (it *evictSlowStoreAction) Revert(pd pdhelper.Executor, store pdhelper.Store) error {
+	if _, ok := it.mitigated[store.Id]; !ok {
+		return fmt.Errorf("store %d is not mitigated by %s", store.Id, ActionEvictSlowStore)
+	}
+	if len(it.mitigated) == 1 {
+		if it.state.SlowStoreSchedulerCreated {
+			if err := pd.RemoveEvictSlowStoreScheduler(); err != nil {
+				return err
+			}
+			it.state.SlowStoreSchedulerCreated = false
+		} else {
+			log.L().Info("evict-slow-store-scheduler is kept, because it is not added by evictor")
+		}
+	}
+	delete(it.mitigated, store.Id)
+	return nil
+}
+
+func (it *evictSlowStoreAction) ListApplied(pd pdhelper.Executor) ([]pdhelper.Store, error) {
+	if len(it.mitigated) == 0 {
+		return nil, nil
+	}
+	existed, err := pd.HasEvictSlowStoreScheduler()
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		// the scheduler has been removed by others, the stores are forgotten by reconcile in the main loop
+		return nil, nil
+	}
+	var result []pdhelper.Store
+	for _, store := range it.mitigated {
+		result = append(result, store)
+	}
+	return result, nil
+}
+
+// forget drops a mitigated store which is not mitigated on pd any more, e.g. the scheduler has been removed by others.
+func (it *evictSlowStoreAction) forget(storeId uint) {
+	if _, ok := it.mitigated[storeId]; !ok {
+		return
+	}
+	delete(it.mitigated, storeId)
+	if len(it.mitigated) == 0 {
+		it.state.SlowStoreSchedulerCreated = false
+	}
+}
@@ -0,0 +1,188 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func mitigatedIds(stores []mitigatedStore) []string {
+	var result []string
+	for _, store := range stores {
+		result = append(result, fmt.Sprintf("%s/%d", store.Action, store.Id))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func recoverIds(candidates []recoverCandidate) []string {
+	var stores []mitigatedStore
+	for _, candidate := range candidates {
+		stores = append(stores, candidate.mitigatedStore)
+	}
+	return mitigatedIds(stores)
+}
+
+func TestEvictor_RecoverRevertsAppliedAction(t *testing.T) {
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "10.0.0.1:20160"},
+		{Id: 2, Address: "10.0.0.2:20160"},
+		{Id: 3, Address: "10.0.0.3:20160"},
+	}
+	pd := newFakeExecutor(stores...)
+	evictor := newTestEvictor(Config{MaxEvicted: 3, Action: ActionEvictSlowStore}, pd)
+
+	// store 1 was evicted by evict-leader before the action is reconfigured
+	if err := evictor.actions[ActionEvictLeader].Apply(pd, stores[0]); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	evictor.state.RecordEvicted(stores[0], ActionEvictLeader, "test", time.Now())
+	for _, store := range stores[1:] {
+		if err := evictor.actions[ActionEvictSlowStore].Apply(pd, store); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		evictor.state.RecordEvicted(store, ActionEvictSlowStore, "test", time.Now())
+	}
+	if !pd.slowStoreActive || !evictor.state.SlowStoreSchedulerCreated {
+		t.Fatalf("evict-slow-store-scheduler should be added and recorded as created by evictor")
+	}
+
+	evicted, err := evictor.getEvicted()
+	if err != nil {
+		t.Fatalf("getEvicted() error = %v", err)
+	}
+	want := []string{"evict-leader/1", "evict-slow-store/2", "evict-slow-store/3"}
+	if got := mitigatedIds(evicted); !reflect.DeepEqual(got, want) {
+		t.Errorf("getEvicted() = %v, want %v", got, want)
+	}
+
+	shouldRecover, err := evictor.findOutShouldRecover(map[string]NodeHealth{
+		"10.0.0.1": Healthy,
+		"10.0.0.2": Healthy,
+		"10.0.0.3": Unhealthy,
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("findOutShouldRecover() error = %v", err)
+	}
+	want = []string{"evict-leader/1", "evict-slow-store/2"}
+	if got := recoverIds(shouldRecover); !reflect.DeepEqual(got, want) {
+		t.Fatalf("findOutShouldRecover() = %v, want %v", got, want)
+	}
+	for _, store := range shouldRecover {
+		if err := evictor.actions[store.Action].Revert(pd, store.Store); err != nil {
+			t.Fatalf("Revert() error = %v", err)
+		}
+	}
+	if len(pd.evicted) != 0 {
+		t.Errorf("evict-leader-scheduler should be removed, got %v", pd.evicted)
+	}
+	if !pd.slowStoreActive {
+		t.Errorf("evict-slow-store-scheduler should be kept for store 3")
+	}
+
+	if err := evictor.actions[ActionEvictSlowStore].Revert(pd, stores[2]); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+	if pd.slowStoreActive {
+		t.Errorf("evict-slow-store-scheduler should be removed with the last mitigated store")
+	}
+}
+
+func TestEvictor_RecoverKeepsForeignSlowStoreScheduler(t *testing.T) {
+	store := pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}
+	pd := newFakeExecutor(store)
+	evictor := newTestEvictor(Config{MaxEvicted: 1, Action: ActionEvictSlowStore}, pd)
+	// the scheduler is added by an operator before evictor mitigates any store
+	_ = pd.AddEvictSlowStoreScheduler()
+
+	action := evictor.actions[ActionEvictSlowStore]
+	if err := action.Apply(pd, store); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if evictor.state.SlowStoreSchedulerCreated {
+		t.Errorf("evict-slow-store-scheduler should not be recorded as created by evictor")
+	}
+	if err := action.Revert(pd, store); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+	if !pd.slowStoreActive {
+		t.Errorf("evict-slow-store-scheduler added by the operator should be kept")
+	}
+}
+
+func TestEvictor_SlowStoreSchedulerRemovedByOthers(t *testing.T) {
+	store := pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}
+	pd := newFakeExecutor(store)
+	evictor := newTestEvictor(Config{MaxEvicted: 1, Action: ActionEvictSlowStore}, pd)
+	if err := evictor.actions[ActionEvictSlowStore].Apply(pd, store); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	evictor.state.RecordEvicted(store, ActionEvictSlowStore, "test", time.Now())
+	evictor.saveState()
+	_ = pd.RemoveEvictSlowStoreScheduler()
+
+	// listing for the status changes nothing
+	if _, err := evictor.getEvicted(); err != nil {
+		t.Fatalf("getEvicted() error = %v", err)
+	}
+	if !evictor.state.Owns(ActionEvictSlowStore, store.Id) || !evictor.state.SlowStoreSchedulerCreated {
+		t.Errorf("state should not be changed by listing evicted stores")
+	}
+
+	// the main loop forgets the store, and persists it
+	if _, err := evictor.findOutShouldRecover(map[string]NodeHealth{"10.0.0.1": Healthy}, time.Now()); err != nil {
+		t.Fatalf("findOutShouldRecover() error = %v", err)
+	}
+	saved, err := evictor.stateStore.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if saved.Owns(ActionEvictSlowStore, store.Id) || saved.SlowStoreSchedulerCreated {
+		t.Errorf("saved state should forget the store and the scheduler, got %+v", saved)
+	}
+	if len(evictor.actions[ActionEvictSlowStore].(*evictSlowStoreAction).mitigated) != 0 {
+		t.Errorf("mitigated stores should be forgotten")
+	}
+}
+
+func TestEvictor_RecoverForeignAndOwned(t *testing.T) {
+	pd := newFakeExecutor(
+		pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"},
+		pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"},
+		pdhelper.Store{Id: 3, Address: "10.0.0.3:20160"},
+	)
+	evictor := newTestEvictor(Config{MaxEvicted: 2, Action: ActionEvictLeader}, pd)
+	// store 1 is evicted by evictor, store 2 is evicted by an operator for maintenance
+	_ = pd.AddEvictScheduler(1)
+	_ = pd.AddEvictScheduler(2)
+	evictor.state.RecordEvicted(pd.stores[0], ActionEvictLeader, "test", time.Now())
+
+	healthMap := map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Healthy, "10.0.0.3": Unhealthy}
+	shouldRecover, err := evictor.findOutShouldRecover(healthMap, time.Now())
+	if err != nil {
+		t.Fatalf("findOutShouldRecover() error = %v", err)
+	}
+	if got, want := recoverIds(shouldRecover), []string{"evict-leader/1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findOutShouldRecover() = %v, want %v", got, want)
+	}
+	if got, want := mitigatedIds(evictor.foreign), []string{"evict-leader/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("foreign = %v, want %v", got, want)
+	}
+
+	// foreign evictions still count toward max-evicted
+	if shouldEvict, err := evictor.findOutShouldEvict(healthMap, nil); err != nil || len(shouldEvict) != 0 {
+		t.Errorf("findOutShouldEvict() = %v, %v, should defer all as max-evicted exceed", shouldEvict, err)
+	}
+
+	// the operator recovered store 1 manually, so evictor forgets it
+	_ = pd.RemoveEvictScheduler(1)
+	if _, err := evictor.findOutShouldRecover(healthMap, time.Now()); err != nil {
+		t.Fatalf("findOutShouldRecover() error = %v", err)
+	}
+	if evictor.state.Owns(ActionEvictLeader, 1) {
+		t.Errorf("ownership of store 1 should be forgotten")
+	}
+}
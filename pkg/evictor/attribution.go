@@ -0,0 +1,202 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// suspicionThreshold is the least ratio of bad links in the fresh links from and to a node, for which the node is
+// suspected to cause them; a node which all others fail to probe reaches it without any bad links probed from it.
+const suspicionThreshold = 0.5
+
+// Suspicion is how much a node is suspected to cause the bad links around it.
+type Suspicion struct {
+	// Score is the ratio of bad links in the fresh links from and to the node.
+	Score      float64 `json:"score"`
+	BadLinks   int     `json:"bad_links"`
+	FreshLinks int     `json:"fresh_links"`
+	// Suspect is true if the node is chosen to explain the bad links around it.
+	Suspect bool `json:"suspect"`
+	// Blamed are the bad links attributed to the node, they count toward BadLinkFuseThreshold.
+	Blamed        []promhelper.Link `json:"blamed"`
+	Justification string            `json:"justification"`
+}
+
+// attributeBadLinks finds a small set of suspected nodes which explain the bad links, by both directions: it picks
+// the node explaining the most unexplained bad links repeatedly, among the nodes whose Score reaches
+// suspicionThreshold, so a slow node is blamed instead of the healthy nodes probing it. Equally suspected nodes are
+// picked together, unless they explain exactly the same links (e.g. both ends of a single slow pair), which is
+// ambiguous; the bad links explained by no suspects are attributed to the node probing from.
+func attributeBadLinks(states map[promhelper.Link]LinkState) map[string]Suspicion {
+	result := make(map[string]Suspicion)
+	var nodes []string
+	unexplained := make(map[promhelper.Link]bool)
+	for link, state := range states {
+		for _, node := range []string{link.From, link.To} {
+			suspicion, ok := result[node]
+			if !ok {
+				nodes = append(nodes, node)
+			}
+			if state != LinkStale {
+				suspicion.FreshLinks++
+			}
+			if state == LinkBad {
+				suspicion.BadLinks++
+			}
+			result[node] = suspicion
+		}
+		if state == LinkBad {
+			unexplained[link] = true
+		}
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		suspicion := result[node]
+		if suspicion.FreshLinks > 0 {
+			suspicion.Score = float64(suspicion.BadLinks) / float64(suspicion.FreshLinks)
+		}
+		result[node] = suspicion
+	}
+
+	explainedBy := make(map[promhelper.Link][]string)
+	for len(unexplained) > 0 {
+		var picked []string
+		var bestExplained int
+		var bestScore float64
+		for _, node := range nodes {
+			suspicion := result[node]
+			if suspicion.Suspect || suspicion.Score < suspicionThreshold {
+				continue
+			}
+			explained := len(incidentLinks(node, unexplained))
+			if explained == 0 {
+				continue
+			}
+			if explained > bestExplained || (explained == bestExplained && suspicion.Score > bestScore) {
+				picked, bestExplained, bestScore = []string{node}, explained, suspicion.Score
+			} else if explained == bestExplained && suspicion.Score == bestScore {
+				picked = append(picked, node)
+			}
+		}
+		candidates := make(map[string][]promhelper.Link)
+		for _, node := range picked {
+			candidates[node] = incidentLinks(node, unexplained)
+		}
+		picked = unambiguous(picked, candidates)
+		if len(picked) == 0 {
+			break
+		}
+		var explained []promhelper.Link
+		for _, node := range picked {
+			links := candidates[node]
+			suspicion := result[node]
+			suspicion.Suspect = true
+			suspicion.Blamed = links
+			suspicion.Justification = fmt.Sprintf("%d of %d fresh links from and to the node are bad, it explains %d bad links",
+				suspicion.BadLinks, suspicion.FreshLinks, len(links))
+			if len(picked) > 1 {
+				suspicion.Justification += fmt.Sprintf(", equally suspected with %s", strings.Join(others(picked, node), ", "))
+			}
+			result[node] = suspicion
+			for _, link := range links {
+				explainedBy[link] = append(explainedBy[link], node)
+			}
+			explained = append(explained, links...)
+		}
+		for _, link := range explained {
+			delete(unexplained, link)
+		}
+	}
+	for link := range unexplained {
+		suspicion := result[link.From]
+		suspicion.Blamed = append(suspicion.Blamed, link)
+		result[link.From] = suspicion
+	}
+
+	for _, node := range nodes {
+		suspicion := result[node]
+		if suspicion.Suspect {
+			continue
+		}
+		sortLinks(suspicion.Blamed)
+		switch {
+		case suspicion.FreshLinks == 0:
+			suspicion.Justification = "there are no fresh links from and to the node"
+		case suspicion.BadLinks == 0:
+			suspicion.Justification = fmt.Sprintf("all of %d fresh links from and to the node are not bad", suspicion.FreshLinks)
+		default:
+			var suspects []string
+			for link, by := range explainedBy {
+				if link.From == node || link.To == node {
+					for _, suspect := range by {
+						if !contains(suspects, suspect) {
+							suspects = append(suspects, suspect)
+						}
+					}
+				}
+			}
+			sort.Strings(suspects)
+			suspicion.Justification = fmt.Sprintf("%d of %d fresh links from and to the node are bad", suspicion.BadLinks, suspicion.FreshLinks)
+			if len(suspects) > 0 {
+				suspicion.Justification += fmt.Sprintf(", explained by %s", strings.Join(suspects, ", "))
+			}
+			if len(suspicion.Blamed) > 0 {
+				suspicion.Justification += fmt.Sprintf(", %d bad links probed from the node are explained by no suspects", len(suspicion.Blamed))
+			}
+		}
+		result[node] = suspicion
+	}
+	return result
+}
+
+// incidentLinks returns the links from or to node, sorted.
+func incidentLinks(node string, links map[promhelper.Link]bool) []promhelper.Link {
+	var result []promhelper.Link
+	for link := range links {
+		if link.From == node || link.To == node {
+			result = append(result, link)
+		}
+	}
+	sortLinks(result)
+	return result
+}
+
+// unambiguous returns the nodes whose links are not the same as the links of any other node.
+func unambiguous(nodes []string, links map[string][]promhelper.Link) []string {
+	var result []string
+	for _, node := range nodes {
+		ambiguous := false
+		for _, other := range nodes {
+			if other != node && reflect.DeepEqual(links[node], links[other]) {
+				ambiguous = true
+				break
+			}
+		}
+		if !ambiguous {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func sortLinks(links []promhelper.Link) {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].From != links[j].From {
+			return links[i].From < links[j].From
+		}
+		return links[i].To < links[j].To
+	})
+}
+
+func others(nodes []string, node string) []string {
+	var result []string
+	for _, item := range nodes {
+		if item != node {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mesh returns the states of links between every two nodes, the links in bad are bad and the others are good.
+func mesh(nodes []string, bad ...promhelper.Link) map[promhelper.Link]LinkState {
+	result := make(map[promhelper.Link]LinkState)
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from != to {
+				result[promhelper.Link{From: from, To: to}] = LinkGood
+			}
+		}
+	}
+	for _, link := range bad {
+		result[link] = LinkBad
+	}
+	return result
+}
+
+func TestAttributeBadLinks(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		name        string
+		states      map[promhelper.Link]LinkState
+		wantSuspect []string
+		wantBlamed  map[string]int
+	}{
+		{
+			name:        "all good",
+			states:      mesh(nodes),
+			wantSuspect: nil,
+			wantBlamed:  map[string]int{},
+		}, {
+			name: "slow node",
+			states: mesh(nodes,
+				promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "d", To: "c"},
+				promhelper.Link{From: "c", To: "a"}, promhelper.Link{From: "c", To: "b"}, promhelper.Link{From: "c", To: "d"}),
+			wantSuspect: []string{"c"},
+			wantBlamed:  map[string]int{"c": 6},
+		}, {
+			name: "slow inbound only",
+			states: mesh(nodes,
+				promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "d", To: "c"},
+				promhelper.Link{From: "c", To: "a"}),
+			wantSuspect: []string{"c"},
+			wantBlamed:  map[string]int{"c": 4},
+		}, {
+			name:        "single bad link",
+			states:      mesh(nodes, promhelper.Link{From: "a", To: "b"}),
+			wantSuspect: nil,
+			wantBlamed:  map[string]int{"a": 1},
+		}, {
+			name:        "ambiguous pair",
+			states:      mesh([]string{"a", "b"}, promhelper.Link{From: "a", To: "b"}, promhelper.Link{From: "b", To: "a"}),
+			wantSuspect: nil,
+			wantBlamed:  map[string]int{"a": 1, "b": 1},
+		}, {
+			name: "two slow nodes",
+			states: mesh(append(nodes, "e", "f"),
+				promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "d", To: "c"},
+				promhelper.Link{From: "e", To: "c"}, promhelper.Link{From: "f", To: "c"}, promhelper.Link{From: "c", To: "a"},
+				promhelper.Link{From: "a", To: "d"}, promhelper.Link{From: "b", To: "d"}, promhelper.Link{From: "e", To: "d"},
+				promhelper.Link{From: "f", To: "d"}, promhelper.Link{From: "d", To: "a"}, promhelper.Link{From: "d", To: "b"}),
+			wantSuspect: []string{"c", "d"},
+			wantBlamed:  map[string]int{"c": 5, "d": 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := attributeBadLinks(tt.states)
+			var suspects []string
+			blamed := make(map[string]int)
+			for _, node := range []string{"a", "b", "c", "d", "e", "f"} {
+				suspicion := got[node]
+				if suspicion.Suspect {
+					suspects = append(suspects, node)
+				}
+				if len(suspicion.Blamed) > 0 {
+					blamed[node] = len(suspicion.Blamed)
+				}
+				if _, ok := got[node]; ok && suspicion.Justification == "" {
+					t.Errorf("justification of %s is empty", node)
+				}
+			}
+			if !reflect.DeepEqual(suspects, tt.wantSuspect) {
+				t.Errorf("suspects = %v, want %v", suspects, tt.wantSuspect)
+			}
+			if !reflect.DeepEqual(blamed, tt.wantBlamed) {
+				t.Errorf("blamed = %v, want %v", blamed, tt.wantBlamed)
+			}
+		})
+	}
+}
+
+func TestEvictor_generateNodeHealthMapSlowNode(t *testing.T) {
+	now := time.Now()
+	series := func(latency time.Duration) promhelper.TimeSeries {
+		var result promhelper.TimeSeries
+		for i := -8; i <= 0; i++ {
+			result = append(result, promhelper.Sample{Timestamp: now.Add(time.Duration(i) * 15 * time.Second), Latency: latency})
+		}
+		return result
+	}
+	evictor := newTestEvictor(Config{
+		Threshold:            time.Second,
+		BadLinkFuseThreshold: 2,
+		PendingForEvict:      time.Minute,
+		PendingForRecover:    time.Minute,
+	}, newFakeExecutor())
+	metrics := make(map[promhelper.Link]promhelper.TimeSeries)
+	nodes := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from == to {
+				continue
+			}
+			// probes to 10.0.0.3 are slow, while probes from it are fine
+			if to == "10.0.0.3" {
+				metrics[promhelper.Link{From: from, To: to}] = series(2 * time.Second)
+			} else {
+				metrics[promhelper.Link{From: from, To: to}] = series(time.Millisecond)
+			}
+		}
+	}
+	got := evictor.generateNodeHealthMap(metrics)
+	want := map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Healthy, "10.0.0.3": Unhealthy}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generateNodeHealthMap() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"fmt"
+)
+
+var errMaxEvictedExceeded = fmt.Errorf("max-evicted exceed")
+
+// evictionBudget limits new evictions by the stores already evicted (including the stores evicted by others): at most
+// MaxEvicted stores, and leaders are not concentrated on a part of label domains (e.g. zones) or stores.
+type evictionBudget struct {
+	config  Config
+	stores  []pdhelper.Store
+	evicted map[uint]bool
+}
+
+func newEvictionBudget(config Config, stores []pdhelper.Store, evicted []mitigatedStore) *evictionBudget {
+	result := &evictionBudget{config: config, stores: stores, evicted: make(map[uint]bool)}
+	for _, store := range evicted {
+		result.evicted[store.Id] = true
+	}
+	return result
+}
+
+// admit returns an error with the reason if store should not be evicted in addition to the evicted stores.
+func (it *evictionBudget) admit(store pdhelper.Store) error {
+	if uint(len(it.evicted)) >= it.config.MaxEvicted {
+		return errMaxEvictedExceeded
+	}
+	if it.config.MaxEvictedPercent > 0 && len(it.stores) > 0 {
+		percent := float64(len(it.evicted)+1) * 100 / float64(len(it.stores))
+		if percent > it.config.MaxEvictedPercent {
+			return fmt.Errorf("evicting %d of %d stores exceeds max evicted percent %v",
+				len(it.evicted)+1, len(it.stores), it.config.MaxEvictedPercent)
+		}
+	}
+	for i, key := range it.config.LocationLabels {
+		value, ok := store.Label(key)
+		if !ok {
+			continue
+		}
+		var others, evicted, eligible int
+		for _, item := range it.stores {
+			if other, ok := item.Label(key); !ok || other != value || item.Id == store.Id {
+				continue
+			}
+			others++
+			if it.evicted[item.Id] {
+				evicted++
+			} else if item.StateName == "" || item.StateName == pdhelper.StoreStateUp {
+				eligible++
+			}
+		}
+		if it.config.MaxEvictedPerDomain > 0 && uint(evicted+1) > it.config.MaxEvictedPerDomain {
+			return fmt.Errorf("%d stores are evicted in %s=%s, which reaches max evicted per domain %d",
+				evicted, key, value, it.config.MaxEvictedPerDomain)
+		}
+		// the finest label (e.g. host) under coarser ones usually has a single store, which has no other stores to
+		// keep leaders in its domain anyway; the coarser domains (e.g. zones) should always keep a leader-eligible store
+		finest := len(it.config.LocationLabels) > 1 && i == len(it.config.LocationLabels)-1
+		if eligible == 0 && !(finest && others == 0) {
+			return fmt.Errorf("no other stores in %s=%s could serve leaders", key, value)
+		}
+	}
+	return nil
+}
+
+// take records store as evicted, after it is admitted.
+func (it *evictionBudget) take(store pdhelper.Store) {
+	it.evicted[store.Id] = true
+}
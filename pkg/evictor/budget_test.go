@@ -0,0 +1,108 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestEvictionBudget_admit(t *testing.T) {
+	// 2 stores in each of 3 zones
+	var stores []pdhelper.Store
+	for i := uint(1); i <= 6; i++ {
+		stores = append(stores, pdhelper.Store{
+			Id:        i,
+			Address:   fmt.Sprintf("10.0.0.%d:20160", i),
+			Labels:    []pdhelper.StoreLabel{{Key: "zone", Value: fmt.Sprintf("z%d", (i+1)/2)}},
+			StateName: pdhelper.StoreStateUp,
+		})
+	}
+	offline := append([]pdhelper.Store{}, stores...)
+	offline[1].StateName = "Offline"
+	hosts := append([]pdhelper.Store{}, stores...)
+	for i := range hosts {
+		hosts[i].Labels = append(hosts[i].Labels, pdhelper.StoreLabel{Key: "host", Value: fmt.Sprintf("h%d", i+1)})
+	}
+	// a single store in each of 3 zones
+	var zones []pdhelper.Store
+	for i := uint(1); i <= 3; i++ {
+		zones = append(zones, pdhelper.Store{
+			Id:      i,
+			Address: fmt.Sprintf("10.0.0.%d:20160", i),
+			Labels: []pdhelper.StoreLabel{
+				{Key: "zone", Value: fmt.Sprintf("z%d", i)}, {Key: "host", Value: fmt.Sprintf("h%d", i)},
+			},
+			StateName: pdhelper.StoreStateUp,
+		})
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		stores  []pdhelper.Store
+		evicted []uint
+		want    []uint
+	}{
+		{"no limits", Config{MaxEvicted: 6}, stores, nil, []uint{1, 2, 3, 4, 5, 6}},
+		{"max evicted", Config{MaxEvicted: 3}, stores, []uint{1}, []uint{2, 3}},
+		{"max evicted percent", Config{MaxEvicted: 6, MaxEvictedPercent: 50}, stores, []uint{1}, []uint{2, 3}},
+		{"no leader-eligible stores in zone", Config{MaxEvicted: 6, LocationLabels: []string{"zone"}}, stores, nil, []uint{1, 3, 5}},
+		{"offline store is not leader-eligible", Config{MaxEvicted: 6, LocationLabels: []string{"zone"}}, offline, nil, []uint{2, 3, 5}},
+		{"max evicted per domain", Config{MaxEvicted: 6, LocationLabels: []string{"zone"}, MaxEvictedPerDomain: 1}, stores, []uint{3}, []uint{1, 5}},
+		{"single store on each host in zones", Config{MaxEvicted: 6, LocationLabels: []string{"zone", "host"}}, hosts, nil, []uint{1, 3, 5}},
+		{"single store in each zone", Config{MaxEvicted: 2, LocationLabels: []string{"zone", "host"}}, zones, nil, nil},
+		{"single store in each zone evicted already", Config{MaxEvicted: 2, LocationLabels: []string{"zone", "host"}}, zones, []uint{1}, nil},
+		{"single store in each zone without hosts", Config{MaxEvicted: 2, LocationLabels: []string{"zone"}}, zones, []uint{1}, nil},
+		{"stores without the label", Config{MaxEvicted: 6, LocationLabels: []string{"rack"}}, stores, nil, []uint{1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicted []mitigatedStore
+			evictedIds := make(map[uint]bool)
+			for _, id := range tt.evicted {
+				evicted = append(evicted, mitigatedStore{Store: tt.stores[id-1], Action: ActionEvictLeader})
+				evictedIds[id] = true
+			}
+			budget := newEvictionBudget(tt.config, tt.stores, evicted)
+			var got []uint
+			for _, store := range tt.stores {
+				if evictedIds[store.Id] {
+					continue
+				}
+				if err := budget.admit(store); err == nil {
+					budget.take(store)
+					got = append(got, store.Id)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("admitted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictor_findOutShouldEvictByZones(t *testing.T) {
+	zone := func(id uint, value string) pdhelper.Store {
+		return pdhelper.Store{
+			Id:      id,
+			Address: fmt.Sprintf("10.0.0.%d:20160", id),
+			Labels:  []pdhelper.StoreLabel{{Key: "zone", Value: value}},
+		}
+	}
+	pd := newFakeExecutor(zone(1, "z1"), zone(2, "z1"), zone(3, "z1"), zone(4, "z2"), zone(5, "z2"))
+	evictor := newTestEvictor(Config{MaxEvicted: 3, LocationLabels: []string{"zone"}, MaxEvictedPerDomain: 1}, pd)
+
+	shouldEvict, err := evictor.findOutShouldEvict(map[string]NodeHealth{
+		"10.0.0.1": Unhealthy, "10.0.0.2": Unhealthy, "10.0.0.3": Healthy, "10.0.0.4": Unhealthy, "10.0.0.5": Unhealthy,
+	}, nil)
+	if err != nil {
+		t.Fatalf("findOutShouldEvict() error = %v", err)
+	}
+	var got []uint
+	for _, candidate := range shouldEvict {
+		got = append(got, candidate.Id)
+	}
+	if want := []uint{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findOutShouldEvict() = %v, want %v", got, want)
+	}
+}
@@ -1,20 +1,168 @@
 package evictor
 
-import "time"
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"fmt"
+	"time"
+)
 
 const VersionV3 string = "v3"
 const VersionV4 string = "v4"
+const VersionV5 string = "v5"
+const VersionV6 string = "v6"
+const VersionV7 string = "v7"
+
+// ExecutorPdCtl shells out to pd-ctl, and ExecutorHTTP talks to the PD HTTP API directly.
+const ExecutorPdCtl string = "pd-ctl"
+const ExecutorHTTP string = "http"
+
+// The policies for Unknown nodes: UnknownIgnore never evicts them and UnknownEvict evicts them like Unhealthy ones;
+// UnknownKeep never recovers them and UnknownRecover recovers them like Healthy ones.
+const UnknownIgnore string = "ignore"
+const UnknownEvict string = "evict"
+const UnknownKeep string = "keep"
+const UnknownRecover string = "recover"
+
+// StateStoreFile keeps the state in StateFile, and StateStorePdEtcd keeps it in the etcd embedded in pd.
+const StateStoreFile string = "file"
+const StateStorePdEtcd string = "pd-etcd"
 
 type Config struct {
-	PrometheusAddress    string
-	PdAddress            string
-	MaxEvicted           uint
-	PdVersion            string
-	Interval             time.Duration
-	Threshold            time.Duration
-	BadLinkFuseThreshold uint
-	PendingForEvict      time.Duration
-	PendingForRecover    time.Duration
+	PrometheusAddress string `json:"prometheus"`
+	// PrometheusQuery is the template of latency query, with the labels of the source and the target of links,
+	// and the extra label matchers replacing promhelper.MatchersPlaceholder in it.
+	PrometheusQuery string `json:"prometheus_query"`
+	// PrometheusSuccessQuery is the template of probe success query on the same links; empty disables it.
+	PrometheusSuccessQuery string   `json:"prometheus_success_query"`
+	PrometheusSourceLabel  string   `json:"prometheus_source_label"`
+	PrometheusTargetLabel  string   `json:"prometheus_target_label"`
+	PrometheusMatchers     []string `json:"prometheus_matchers"`
+	PdAddress              string   `json:"pd"`
+	MaxEvicted             uint     `json:"max_evicted"`
+	// MaxEvictedPercent is the percentage of all stores which could be evicted; 0 disables it.
+	MaxEvictedPercent float64 `json:"max_evicted_percent"`
+	// LocationLabels are the keys of store labels, like zone, rack and host. A store is not evicted if no other
+	// stores with the same label could serve leaders, or MaxEvictedPerDomain stores with the same label are evicted.
+	LocationLabels      []string `json:"location_labels"`
+	MaxEvictedPerDomain uint     `json:"max_evicted_per_domain"`
+	PdVersion           string   `json:"pd_version"`
+	PdExecutor          string   `json:"pd_executor"`
+	// PdVersionCheckInterval is the interval for re-detecting pd version; 0 disables it.
+	PdVersionCheckInterval time.Duration `json:"pd_version_check_interval"`
+	// Action is the name of the Action applied on Unhealthy stores
+	Action string `json:"action"`
+	// StateFile is the local file which keeps the state of evictor if StateStore is file; empty keeps it in memory only.
+	StateFile  string `json:"state_file"`
+	StateStore string `json:"state_store"`
+	// MappingFile maps probe endpoints to stores explicitly, in MappingFormat: static, tiup or ansible; empty disables it.
+	MappingFile   string `json:"mapping_file"`
+	MappingFormat string `json:"mapping_format"`
+	// KubernetesNamespace resolves the DNS names of tikv pods in this namespace by kubernetes api; empty disables it.
+	KubernetesNamespace string `json:"kubernetes_namespace"`
+	KubernetesSelector  string `json:"kubernetes_selector"`
+	// KubernetesAPIServer is the address of kubernetes api server without authentication; empty uses the in-cluster service account.
+	KubernetesAPIServer  string `json:"kubernetes_api_server"`
+	KubernetesLogMapping bool   `json:"kubernetes_log_mapping"`
+	// UnstableLeaderWeight is the leader weight set on Unstable stores; 0 disables it.
+	UnstableLeaderWeight float64       `json:"unstable_leader_weight"`
+	Interval             time.Duration `json:"interval"`
+	Threshold            time.Duration `json:"threshold"`
+	// RecoverThreshold is the latency under which a link is good, it should not be above Threshold, so links hovering
+	// around Threshold do not flap between bad and good; 0 uses Threshold.
+	RecoverThreshold time.Duration `json:"recover_threshold"`
+	// LossThreshold is the percentage of failed probes in PendingForEvict, over which a link is bad; 0 disables it.
+	LossThreshold float64 `json:"loss_threshold"`
+	// EvictRule decides whether the samples in PendingForEvict exceed Threshold, and RecoverRule decides whether
+	// the samples in PendingForRecover stay below it; the zero value requires every sample.
+	EvictRule            promhelper.Rule `json:"evict_rule"`
+	RecoverRule          promhelper.Rule `json:"recover_rule"`
+	BadLinkFuseThreshold uint            `json:"bad_link_fuse_threshold"`
+	// StaleThreshold is the max age of the last sample of a fresh link; 0 only treats links without samples as stale.
+	StaleThreshold       time.Duration `json:"stale_threshold"`
+	UnknownEvictPolicy   string        `json:"unknown_evict_policy"`
+	UnknownRecoverPolicy string        `json:"unknown_recover_policy"`
+	// DegradedBadLinks and DegradedUnhealthyNodes are the percentages of bad links and unhealthy nodes, over which
+	// evictor enters the degraded mode; 0 disables them. DegradedProber enters it if all bad links are from a prober
+	// which is not probed by others.
+	DegradedBadLinks       float64 `json:"degraded_bad_links"`
+	DegradedUnhealthyNodes float64 `json:"degraded_unhealthy_nodes"`
+	DegradedProber         bool    `json:"degraded_prober"`
+	// FlapWindow is the duration after a recovery, within which evicting the store again is a flap; 0 disables it.
+	// Each flap doubles how long the store should keep healthy before recovered, from PendingForRecover (or Interval
+	// if larger) up to FlapMaxHold, and one flap is forgiven for each FlapDecay without flapping.
+	FlapWindow        time.Duration `json:"flap_window"`
+	FlapMaxHold       time.Duration `json:"flap_max_hold"`
+	FlapDecay         time.Duration `json:"flap_decay"`
+	PendingForEvict   time.Duration `json:"pending_for_evict"`
+	PendingForRecover time.Duration `json:"pending_for_recover"`
+}
+
+// QueryConfig returns the configuration of prometheus queries, the defaults are used for empty fields
+// except PrometheusSuccessQuery.
+func (it Config) QueryConfig() promhelper.QueryConfig {
+	result := promhelper.DefaultQueryConfig()
+	if it.PrometheusQuery != "" {
+		result.LatencyQuery = it.PrometheusQuery
+	}
+	if it.PrometheusSourceLabel != "" {
+		result.SourceLabel = it.PrometheusSourceLabel
+	}
+	if it.PrometheusTargetLabel != "" {
+		result.TargetLabel = it.PrometheusTargetLabel
+	}
+	result.SuccessQuery = it.PrometheusSuccessQuery
+	result.Matchers = it.PrometheusMatchers
+	return result
+}
+
+// Validate checks the values which could not be checked by their types.
+func (it Config) Validate() error {
+	if it.RecoverThreshold < 0 {
+		return fmt.Errorf("recover threshold should not be negative, got %v", it.RecoverThreshold)
+	}
+	if it.RecoverThreshold > it.Threshold {
+		return fmt.Errorf("recover threshold %v should not be above threshold %v", it.RecoverThreshold, it.Threshold)
+	}
+	if it.DegradedBadLinks < 0 || it.DegradedBadLinks > 100 || it.DegradedUnhealthyNodes < 0 || it.DegradedUnhealthyNodes > 100 {
+		return fmt.Errorf("degraded bad links and degraded unhealthy nodes should be percentages between 0 and 100, got %v and %v",
+			it.DegradedBadLinks, it.DegradedUnhealthyNodes)
+	}
+	if it.MaxEvictedPercent < 0 || it.MaxEvictedPercent > 100 {
+		return fmt.Errorf("max evicted percent should be a percentage between 0 and 100, got %v", it.MaxEvictedPercent)
+	}
+	if it.FlapWindow < 0 || it.FlapMaxHold < 0 || it.FlapDecay < 0 {
+		return fmt.Errorf("flap window, flap max hold and flap decay should not be negative, got %v, %v and %v",
+			it.FlapWindow, it.FlapMaxHold, it.FlapDecay)
+	}
+	if it.PendingForEvict < 0 || it.PendingForRecover < 0 {
+		return fmt.Errorf("pending for evict and pending for recover should not be negative, got %v and %v",
+			it.PendingForEvict, it.PendingForRecover)
+	}
+	if it.LossThreshold < 0 || it.LossThreshold > 100 {
+		return fmt.Errorf("loss threshold should be a percentage between 0 and 100, got %v", it.LossThreshold)
+	}
+	if it.LossThreshold > 0 && it.PrometheusSuccessQuery == "" {
+		return fmt.Errorf("loss threshold requires the success query, which tells failed probes")
+	}
+	switch it.UnknownEvictPolicy {
+	case UnknownIgnore, UnknownEvict, "":
+	default:
+		return fmt.Errorf("unsupported evict policy for unknown nodes %s", it.UnknownEvictPolicy)
+	}
+	switch it.UnknownRecoverPolicy {
+	case UnknownKeep, UnknownRecover, "":
+	default:
+		return fmt.Errorf("unsupported recover policy for unknown nodes %s", it.UnknownRecoverPolicy)
+	}
+	return nil
+}
+
+// recoverThreshold returns RecoverThreshold, or Threshold if it is not set.
+func (it Config) recoverThreshold() time.Duration {
+	if it.RecoverThreshold > 0 {
+		return it.RecoverThreshold
+	}
+	return it.Threshold
 }
 
 func (it Config) RequiredMaxTimeRange() time.Duration {
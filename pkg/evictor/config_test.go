@@ -0,0 +1,41 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"default", Config{}, false},
+		{"loss threshold", Config{LossThreshold: 20, PrometheusSuccessQuery: promhelper.DefaultSuccessQuery}, false},
+		{"loss threshold without success query", Config{LossThreshold: 20}, true},
+		{"negative loss threshold", Config{LossThreshold: -1}, true},
+		{"loss threshold over 100", Config{LossThreshold: 101}, true},
+		{"unknown policies", Config{UnknownEvictPolicy: UnknownEvict, UnknownRecoverPolicy: UnknownRecover}, false},
+		{"unsupported evict policy", Config{UnknownEvictPolicy: "recover"}, true},
+		{"unsupported recover policy", Config{UnknownRecoverPolicy: "evict"}, true},
+		{"recover threshold", Config{Threshold: time.Second, RecoverThreshold: 800 * time.Millisecond}, false},
+		{"recover threshold above threshold", Config{Threshold: time.Second, RecoverThreshold: 2 * time.Second}, true},
+		{"negative recover threshold", Config{Threshold: time.Second, RecoverThreshold: -1}, true},
+		{"negative pending for recover", Config{PendingForRecover: -time.Second}, true},
+		{"degraded", Config{DegradedBadLinks: 50, DegradedUnhealthyNodes: 50}, false},
+		{"max evicted percent over 100", Config{MaxEvictedPercent: 150}, true},
+		{"flap dampening", Config{FlapWindow: time.Hour, FlapMaxHold: time.Hour, FlapDecay: time.Hour}, false},
+		{"negative flap window", Config{FlapWindow: -time.Hour}, true},
+		{"degraded bad links over 100", Config{DegradedBadLinks: 120}, true},
+		{"negative degraded unhealthy nodes", Config{DegradedUnhealthyNodes: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
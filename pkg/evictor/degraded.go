@@ -0,0 +1,144 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// The reasons of the degraded mode.
+const (
+	DegradedBadLinks       = "bad-links"
+	DegradedUnhealthyNodes = "unhealthy-nodes"
+	DegradedProber         = "prober"
+)
+
+// DegradedStatus is why evictor is in the degraded mode, in which it neither evicts nor recovers any stores.
+type DegradedStatus struct {
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail"`
+	Since  time.Time `json:"since"`
+	// ClearSince is when the degradation was not detected any more, it leaves the degraded mode after PendingForRecover.
+	ClearSince *time.Time `json:"clear_since,omitempty"`
+}
+
+// detectDegradation returns the reason if most links or nodes are bad at once, which is more likely caused by a switch
+// or the prober than by the tikv themselves; it is empty if the cluster is not degraded. The bad links explained by a
+// few slow nodes do not count, but all suspects count as unhealthy nodes.
+func (it *Evictor) detectDegradation(states map[promhelper.Link]LinkState, healthMap map[string]NodeHealth) (string, string) {
+	var fresh, bad []promhelper.Link
+	targets := make(map[string]bool)
+	for link, state := range states {
+		targets[link.To] = true
+		if state == LinkStale {
+			continue
+		}
+		fresh = append(fresh, link)
+		if state == LinkBad {
+			bad = append(bad, link)
+		}
+	}
+	if it.config.DegradedProber && len(bad) >= 2 {
+		// a prober which is never probed by others is the common source of all bad links
+		prober := bad[0].From
+		for _, link := range bad {
+			if link.From != prober {
+				prober = ""
+				break
+			}
+		}
+		if prober != "" && !targets[prober] {
+			return DegradedProber, fmt.Sprintf("all of %d bad links are probed from %s", len(bad), prober)
+		}
+	}
+	suspicions := attributeBadLinks(states)
+	explained := it.explainedBySlowNodes(states, suspicions)
+	if it.config.DegradedBadLinks > 0 && len(fresh) > 0 {
+		unexplained := len(bad) - len(explained)
+		ratio := float64(unexplained) * 100 / float64(len(fresh))
+		if ratio > it.config.DegradedBadLinks {
+			return DegradedBadLinks, fmt.Sprintf("%d of %d fresh links are bad, which are not explained by suspected nodes",
+				unexplained, len(fresh))
+		}
+	}
+	if it.config.DegradedUnhealthyNodes > 0 {
+		var known, unhealthy int
+		for node, health := range healthMap {
+			if health == Unknown && !suspicions[node].Suspect {
+				continue
+			}
+			known++
+			if health == Unhealthy || suspicions[node].Suspect {
+				unhealthy++
+			}
+		}
+		if known > 0 && float64(unhealthy)*100/float64(known) > it.config.DegradedUnhealthyNodes {
+			return DegradedUnhealthyNodes, fmt.Sprintf("%d of %d nodes are unhealthy", unhealthy, known)
+		}
+	}
+	return "", ""
+}
+
+// explainedBySlowNodes returns the bad links blamed on suspects, if the suspects look like a few slow nodes rather
+// than a side of a partition: they are less than DegradedUnhealthyNodes (or half if disabled) of all nodes, and the
+// fresh links between them are bad as well; otherwise it returns nothing, so all bad links count.
+func (it *Evictor) explainedBySlowNodes(states map[promhelper.Link]LinkState, suspicions map[string]Suspicion) map[promhelper.Link]bool {
+	result := make(map[promhelper.Link]bool)
+	var suspects int
+	for _, suspicion := range suspicions {
+		if suspicion.Suspect {
+			suspects++
+		}
+	}
+	share := it.config.DegradedUnhealthyNodes
+	if share <= 0 {
+		share = 50
+	}
+	if suspects == 0 || float64(suspects)*100/float64(len(suspicions)) >= share {
+		return result
+	}
+	for link, state := range states {
+		if state != LinkStale && state != LinkBad && suspicions[link.From].Suspect && suspicions[link.To].Suspect {
+			return result
+		}
+	}
+	for _, suspicion := range suspicions {
+		if suspicion.Suspect {
+			for _, link := range suspicion.Blamed {
+				result[link] = true
+			}
+		}
+	}
+	return result
+}
+
+// checkDegraded enters the degraded mode once any degradation is detected, and leaves it after no degradation is
+// detected for PendingForRecover; it returns true in the degraded mode.
+func (it *Evictor) checkDegraded(now time.Time, states map[promhelper.Link]LinkState, healthMap map[string]NodeHealth) bool {
+	reason, detail := it.detectDegradation(states, healthMap)
+	if reason != "" {
+		if it.degraded == nil {
+			log.L().With(zap.String("reason", reason)).With(zap.String("detail", detail)).
+				Error("cluster is degraded, it will not evict or recover any tikv nodes until the cluster stabilizes")
+			it.metrics.DegradedTransitions.Inc()
+			it.degraded = &DegradedStatus{Since: now}
+		}
+		it.degraded.Reason = reason
+		it.degraded.Detail = detail
+		it.degraded.ClearSince = nil
+	} else if it.degraded != nil {
+		if it.degraded.ClearSince == nil {
+			it.degraded.ClearSince = &now
+		}
+		if now.Sub(*it.degraded.ClearSince) >= it.config.PendingForRecover {
+			log.L().With(zap.Time("since", it.degraded.Since)).Info("cluster is stable again, leave the degraded mode")
+			it.degraded = nil
+		} else {
+			log.L().With(zap.Time("clear-since", *it.degraded.ClearSince)).Info("cluster is stabilizing, keep the degraded mode")
+		}
+	}
+	it.metrics.Degraded.Set(flag(it.degraded != nil))
+	return it.degraded != nil
+}
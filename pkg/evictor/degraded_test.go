@@ -0,0 +1,130 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"testing"
+	"time"
+)
+
+func TestEvictor_detectDegradation(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	// a central prober p probes a, b and c
+	central := func(bad ...string) map[promhelper.Link]LinkState {
+		result := make(map[promhelper.Link]LinkState)
+		for _, node := range []string{"a", "b", "c"} {
+			result[promhelper.Link{From: "p", To: node}] = LinkGood
+		}
+		for _, node := range bad {
+			result[promhelper.Link{From: "p", To: node}] = LinkBad
+		}
+		return result
+	}
+	tests := []struct {
+		name      string
+		states    map[promhelper.Link]LinkState
+		healthMap map[string]NodeHealth
+		want      string
+	}{
+		{"all good", mesh(nodes), nil, ""},
+		{"one slow node", mesh(nodes,
+			promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "d", To: "c"}),
+			map[string]NodeHealth{"a": Healthy, "b": Healthy, "c": Unhealthy, "d": Healthy}, ""},
+		{"one slow node of three", mesh([]string{"a", "b", "c"},
+			promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "c", To: "a"},
+			promhelper.Link{From: "c", To: "b"}),
+			map[string]NodeHealth{"a": Healthy, "b": Healthy, "c": Unhealthy}, ""},
+		{"bad links spread over all nodes", mesh([]string{"a", "b", "c", "d", "e"},
+			promhelper.Link{From: "a", To: "b"}, promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "c", To: "d"},
+			promhelper.Link{From: "d", To: "e"}, promhelper.Link{From: "e", To: "a"}, promhelper.Link{From: "a", To: "c"},
+			promhelper.Link{From: "b", To: "d"}, promhelper.Link{From: "c", To: "e"}, promhelper.Link{From: "d", To: "a"},
+			promhelper.Link{From: "e", To: "b"}, promhelper.Link{From: "a", To: "d"}),
+			map[string]NodeHealth{"a": Healthy, "b": Healthy, "c": Healthy, "d": Healthy, "e": Healthy}, DegradedBadLinks},
+		{"all links bad", mesh([]string{"a", "b", "c"},
+			promhelper.Link{From: "a", To: "b"}, promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "b", To: "a"},
+			promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "c", To: "a"}, promhelper.Link{From: "c", To: "b"}),
+			map[string]NodeHealth{"a": Unhealthy, "b": Unhealthy, "c": Unhealthy}, DegradedBadLinks},
+		{"most nodes unhealthy", mesh(nodes),
+			map[string]NodeHealth{"a": Unhealthy, "b": Unhealthy, "c": Unhealthy, "d": Healthy}, DegradedUnhealthyNodes},
+		{"unknown nodes are ignored", mesh(nodes),
+			map[string]NodeHealth{"a": Unhealthy, "b": Unknown, "c": Unknown, "d": Healthy}, ""},
+		{"bad links from the central prober", central("a", "b"), nil, DegradedProber},
+		{"single bad link from the central prober", central("a"), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictor := newTestEvictor(Config{DegradedBadLinks: 50, DegradedUnhealthyNodes: 50, DegradedProber: true}, newFakeExecutor())
+			if got, _ := evictor.detectDegradation(tt.states, tt.healthMap); got != tt.want {
+				t.Errorf("detectDegradation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictor_detectDegradationByBadLinks(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d", "e"}
+	// all links between two sides are bad
+	partition := func(left, right []string) map[promhelper.Link]LinkState {
+		var bad []promhelper.Link
+		for _, from := range left {
+			for _, to := range right {
+				bad = append(bad, promhelper.Link{From: from, To: to}, promhelper.Link{From: to, To: from})
+			}
+		}
+		return mesh(nodes, bad...)
+	}
+	all := make(map[promhelper.Link]LinkState)
+	for link := range mesh(nodes) {
+		all[link] = LinkBad
+	}
+	tests := []struct {
+		name   string
+		states map[promhelper.Link]LinkState
+		want   string
+	}{
+		{"all links bad", all, DegradedBadLinks},
+		{"one slow node", partition([]string{"a"}, []string{"b", "c", "d", "e"}), ""},
+		{"two slow nodes", mesh(nodes,
+			promhelper.Link{From: "a", To: "b"}, promhelper.Link{From: "b", To: "a"},
+			promhelper.Link{From: "a", To: "c"}, promhelper.Link{From: "c", To: "a"},
+			promhelper.Link{From: "a", To: "d"}, promhelper.Link{From: "d", To: "a"},
+			promhelper.Link{From: "a", To: "e"}, promhelper.Link{From: "e", To: "a"},
+			promhelper.Link{From: "b", To: "c"}, promhelper.Link{From: "c", To: "b"},
+			promhelper.Link{From: "b", To: "d"}, promhelper.Link{From: "d", To: "b"},
+			promhelper.Link{From: "b", To: "e"}, promhelper.Link{From: "e", To: "b"}), ""},
+		{"partition", partition([]string{"a", "b"}, []string{"c", "d", "e"}), DegradedBadLinks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictor := newTestEvictor(Config{DegradedBadLinks: 50}, newFakeExecutor())
+			if got, _ := evictor.detectDegradation(tt.states, nil); got != tt.want {
+				t.Errorf("detectDegradation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictor_checkDegraded(t *testing.T) {
+	start := time.Now()
+	evictor := newTestEvictor(Config{DegradedUnhealthyNodes: 50, PendingForRecover: time.Minute}, newFakeExecutor())
+	degraded := map[string]NodeHealth{"a": Unhealthy, "b": Unhealthy, "c": Healthy}
+	stable := map[string]NodeHealth{"a": Healthy, "b": Healthy, "c": Healthy}
+	steps := []struct {
+		elapsed   time.Duration
+		healthMap map[string]NodeHealth
+		want      bool
+	}{
+		{0, stable, false},
+		{15 * time.Second, degraded, true},
+		{30 * time.Second, stable, true},
+		// degraded again before stabilizing for PendingForRecover
+		{45 * time.Second, degraded, true},
+		{60 * time.Second, stable, true},
+		{105 * time.Second, stable, true},
+		{120 * time.Second, stable, false},
+	}
+	for _, step := range steps {
+		if got := evictor.checkDegraded(start.Add(step.elapsed), nil, step.healthMap); got != step.want {
+			t.Errorf("checkDegraded() at %v = %v, want %v", step.elapsed, got, step.want)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package evictor
 
 import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
+	"auto-failover-tikv-leader-evict/pkg/kubehelper"
 	"auto-failover-tikv-leader-evict/pkg/log"
 	"auto-failover-tikv-leader-evict/pkg/pdhelper"
 	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"auto-failover-tikv-leader-evict/pkg/state"
 	"context"
 	"fmt"
 	"go.uber.org/zap"
-	"strings"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -17,37 +24,274 @@ const (
 	Healthy   NodeHealth = "healthy"
 	Unhealthy NodeHealth = "unhealthy"
 	Unstable  NodeHealth = "unstable"
+	// Unknown nodes have no fresh samples on any link.
+	Unknown NodeHealth = "unknown"
+	// Recovering nodes have no bad links, but some links have not been good for PendingForRecover yet,
+	// so the stores on them are neither evicted nor recovered.
+	Recovering NodeHealth = "recovering"
 )
 
 func NewEvictor(config Config) (*Evictor, error) {
-	queryClient, err := promhelper.NewQueryClient(config.PrometheusAddress)
-	var pd pdhelper.Executor
-	log.L().Info(fmt.Sprintf("evictor is configured with pd %s", config.PdVersion))
-	if config.PdVersion == VersionV3 {
-		pd = pdhelper.NewExecutorV3(config.PdAddress)
-	} else if config.PdVersion == VersionV4 {
-		pd = pdhelper.NewExecutorV4(config.PdAddress)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	queryClient, err := promhelper.NewQueryClient(config.PrometheusAddress, config.QueryConfig())
+	if err != nil {
+		return nil, err
+	}
+	err = retryStartup("check prometheus query", func() error {
+		checkCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return queryClient.CheckQuery(checkCtx)
+	}, func(err error) bool {
+		_, invalid := err.(*promhelper.InvalidQueryError)
+		return !invalid
+	})
+	if err != nil {
+		return nil, err
+	}
+	version, err := resolvePdVersion(config)
+	if err != nil {
+		return nil, err
+	}
+	metrics := NewMetrics()
+	pd, err := newExecutor(config, version)
+	if err != nil {
+		return nil, err
+	}
+	stateStore, err := newStateStore(config)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := stateStore.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %v", err)
+	}
+	mapper, err := newMapper(config)
+	if err != nil {
+		return nil, err
+	}
+	actions := newActions(loaded)
+	if _, ok := actions[config.Action]; !ok {
+		return nil, fmt.Errorf("unsupported action %s", config.Action)
+	}
+	if config.Action == ActionEvictSlowStore {
+		if capabilities, _ := pdhelper.CapabilitiesFor(version); !capabilities.EvictSlowStoreScheduler {
+			return nil, fmt.Errorf("action %s is not supported by pd %s", config.Action, version)
+		}
+	}
+	instance := &Evictor{
+		config:           config,
+		prom:             queryClient,
+		pd:               instrumentExecutor(pd, metrics),
+		mapper:           mapper,
+		metrics:          metrics,
+		actions:          actions,
+		stateStore:       stateStore,
+		state:            loaded,
+		pdVersion:        version,
+		lastVersionCheck: time.Now(),
+		healthySince:     make(map[uint]time.Time),
+	}
+	return instance, nil
+}
+
+func newMapper(config Config) (*addrhelper.Mapper, error) {
+	var resolver addrhelper.Resolver = net.DefaultResolver
+	if config.KubernetesNamespace != "" {
+		var client kubehelper.Client
+		if config.KubernetesAPIServer != "" {
+			client = kubehelper.NewRESTClient(config.KubernetesAPIServer)
+		} else {
+			inCluster, err := kubehelper.NewInClusterClient()
+			if err != nil {
+				return nil, fmt.Errorf("failed to create kubernetes client: %v", err)
+			}
+			client = inCluster
+		}
+		resolver = kubehelper.NewResolver(client, config.KubernetesNamespace, config.KubernetesSelector, resolver, config.KubernetesLogMapping)
+		log.L().With(zap.String("namespace", config.KubernetesNamespace)).With(zap.String("selector", config.KubernetesSelector)).Info("resolve tikv pods by kubernetes api")
+	}
+	if config.MappingFile == "" {
+		return addrhelper.NewMapper(resolver, nil), nil
+	}
+	mapping, err := addrhelper.LoadMapping(config.MappingFile, config.MappingFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mapping: %v", err)
+	}
+	log.L().With(zap.Any("mapping", mapping)).Info("mapping loaded")
+	return addrhelper.NewMapper(resolver, mapping), nil
+}
+
+func newStateStore(config Config) (state.Store, error) {
+	switch config.StateStore {
+	case StateStoreFile, "":
+		return state.NewStore(config.StateFile), nil
+	case StateStorePdEtcd:
+		return state.NewEtcdStore(etcdhelper.NewClient(config.PdAddress), state.DefaultEtcdKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported state store %s", config.StateStore)
+	}
+}
+
+// reloadState loads the state again, since it might be changed by other replicas before this one becomes leader,
+// then reconciles it with pd.
+func (it *Evictor) reloadState() {
+	loaded, err := it.stateStore.Load()
+	if err != nil {
+		log.L().With(zap.Error(err)).Error("failed to reload evictor state; keep using the current one")
+	} else {
+		it.state = loaded
+		it.actions = newActions(loaded)
+	}
+	if evicted, err := it.getEvicted(); err != nil {
+		log.L().With(zap.Error(err)).Warn("failed to reconcile state with pd")
 	} else {
-		return nil, fmt.Errorf("unsupported pd version %s", config.PdVersion)
+		it.reconcile(evicted)
+	}
+	log.L().With(zap.Any("state", it.state)).Info("evictor state loaded")
+}
+
+// resolvePdVersion returns the overridden pd version if present, otherwise detects it from pd.
+func resolvePdVersion(config Config) (string, error) {
+	if config.PdVersion != "" {
+		if _, err := pdhelper.CapabilitiesFor(config.PdVersion); err != nil {
+			return "", err
+		}
+		log.L().With(zap.String("version", config.PdVersion)).Info("pd version is overridden by configuration")
+		return config.PdVersion, nil
+	}
+	var detected string
+	err := retryStartup("detect pd version", func() error {
+		var err error
+		detected, err = pdhelper.DetectVersion(config.PdAddress)
+		return err
+	}, func(error) bool { return true })
+	if err != nil {
+		return "", fmt.Errorf("failed to detect pd version: %v", err)
+	}
+	version, err := supportedVersion(detected)
+	if err != nil {
+		return "", err
+	}
+	log.L().With(zap.String("detected", detected)).With(zap.String("version", version)).Info("pd version detected")
+	return version, nil
+}
+
+// startupTimeout is how long evictor keeps retrying the checks at startup, e.g. when pd or prometheus is restarting
+// at the same time, before it gives up and exits non-zero.
+var startupTimeout = 5 * time.Minute
+
+// startupBackoff is the first interval between retries at startup, it doubles after each retry up to 30s.
+var startupBackoff = time.Second
+
+// retryStartup calls check until it succeeds, the error is not retryable, or startupTimeout is reached.
+func retryStartup(name string, check func() error, retryable func(error) bool) error {
+	deadline := time.Now().Add(startupTimeout)
+	backoff := startupBackoff
+	for {
+		err := check()
+		if err == nil || !retryable(err) || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		log.L().With(zap.Error(err)).With(zap.Duration("backoff", backoff)).Warn("failed to " + name + " at startup, it will retry")
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
 	}
+}
+
+// supportedVersion converts a detected version like "v5.4.3" into the major version like "v5".
+func supportedVersion(detected string) (string, error) {
+	capabilities, err := pdhelper.CapabilitiesFor(detected)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d", capabilities.Major), nil
+}
 
+func newExecutor(config Config, version string) (pdhelper.Executor, error) {
+	log.L().Info(fmt.Sprintf("evictor is configured with pd %s via %s", version, config.PdExecutor))
+	capabilities, err := pdhelper.CapabilitiesFor(version)
 	if err != nil {
 		return nil, err
 	}
-	return &Evictor{
-		config: config,
-		prom:   queryClient,
-		pd:     pd,
-	}, nil
+	switch config.PdExecutor {
+	case ExecutorHTTP:
+		return pdhelper.NewExecutorHTTP(config.PdAddress, capabilities), nil
+	case ExecutorPdCtl, "":
+		// pd-ctl of v4 and later shares the same commands for evict-leader-scheduler
+		if !capabilities.SingleEvictLeaderScheduler {
+			return pdhelper.NewExecutorV3(config.PdAddress), nil
+		}
+		return pdhelper.NewExecutorV4(config.PdAddress), nil
+	default:
+		return nil, fmt.Errorf("unsupported pd executor %s", config.PdExecutor)
+	}
 }
 
 type Evictor struct {
-	config Config
-	pd     pdhelper.Executor
-	prom   *promhelper.QueryClient
+	config     Config
+	pd         pdhelper.Executor
+	prom       *promhelper.QueryClient
+	actions    map[string]Action
+	stateStore state.Store
+	state      *state.State
+	// foreign is the last seen stores which are evicted by others
+	foreign          []mitigatedStore
+	statusLock       sync.RWMutex
+	status           Status
+	pdVersion        string
+	lastVersionCheck time.Time
+	metrics          *Metrics
+	mapper           *addrhelper.Mapper
+	// degraded is not nil in the degraded mode
+	degraded *DegradedStatus
+	// healthySince is since when each evicted store could be recovered, it is not persisted
+	healthySince map[uint]time.Time
+}
+
+// Metrics returns the prometheus metrics of evictor itself.
+func (it *Evictor) Metrics() *Metrics {
+	return it.metrics
+}
+
+// refreshPdVersion re-detects pd version periodically, and switches the executor after pd upgraded.
+// It keeps the current executor if the detection failed or the new version is unsupported.
+func (it *Evictor) refreshPdVersion() {
+	if it.config.PdVersion != "" || it.config.PdVersionCheckInterval <= 0 {
+		return
+	}
+	if time.Since(it.lastVersionCheck) < it.config.PdVersionCheckInterval {
+		return
+	}
+	it.lastVersionCheck = time.Now()
+	detected, err := pdhelper.DetectVersion(it.config.PdAddress)
+	if err != nil {
+		log.L().With(zap.Error(err)).Warn("failed to detect pd version; keep using the current executor")
+		return
+	}
+	version, err := supportedVersion(detected)
+	if err != nil {
+		log.L().With(zap.Error(err)).With(zap.String("current", it.pdVersion)).Error("pd has been upgraded to an unsupported version; keep using the current executor")
+		return
+	}
+	if version == it.pdVersion {
+		return
+	}
+	pd, err := newExecutor(it.config, version)
+	if err != nil {
+		log.L().With(zap.Error(err)).Error("failed to switch executor")
+		return
+	}
+	log.L().With(zap.String("from", it.pdVersion)).With(zap.String("to", version)).With(zap.String("detected", detected)).Info("pd version changed, executor switched")
+	it.pd = instrumentExecutor(pd, it.metrics)
+	it.pdVersion = version
 }
 
 func (it *Evictor) Run(ctx context.Context) error {
+	it.reloadState()
 	ticker := time.NewTicker(it.config.Interval)
 	defer ticker.Stop()
 
@@ -66,9 +310,17 @@ func (it *Evictor) Run(ctx context.Context) error {
 }
 
 func (it *Evictor) loopForever(ctx context.Context) error {
+	defer func(start time.Time) {
+		it.metrics.LoopDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+	it.refreshPdVersion()
+
 	// it follows best-effort pattern
+	queryStart := time.Now()
 	metrics, err := it.prom.FetchNodeLatencyMetrics(ctx, it.config.RequiredMaxTimeRange())
+	it.metrics.PromQueryDuration.WithLabelValues("node_latency", resultOf(err)).Observe(time.Since(queryStart).Seconds())
 	if err != nil {
+		it.metrics.Failures.WithLabelValues(FailureFetchMetrics).Inc()
 		log.L().With(zap.Error(err)).Error("failed to fetch metrics; it will not do any operations")
 		return err
 	}
@@ -78,64 +330,142 @@ func (it *Evictor) loopForever(ctx context.Context) error {
 
 	healthMap := it.generateNodeHealthMap(metrics)
 	log.L().With(zap.Any("status", healthMap)).Debug("nodes status")
+	defer it.updateStatus(healthMap, metrics)
+	now := time.Now()
+	if it.checkDegraded(now, it.classifyLinks(metrics), healthMap) {
+		return nil
+	}
+	it.mitigate(ctx, healthMap, it.severities(metrics), now)
+	return nil
+}
 
+// mitigate evicts the most severe Unhealthy nodes, recovers Healthy nodes and adjusts the leader weights of
+// Unstable nodes. It stops once ctx is done, which is canceled after this replica loses its leadership, so it never
+// mutates pd together with the new leader.
+func (it *Evictor) mitigate(ctx context.Context, healthMap map[string]NodeHealth, severities map[string]Severity, now time.Time) {
 	// evict
-	if shouldEvict, err := it.findOutShouldEvict(healthMap); err != nil {
+	if shouldEvict, err := it.findOutShouldEvict(healthMap, severities); err != nil {
+		it.metrics.Failures.WithLabelValues(FailureFindShouldEvict).Inc()
 		log.L().With(zap.Error(err)).Error("failed to find out should evicted stores; it will not evict any nodes at this time")
 	} else {
-		for _, store := range shouldEvict {
-			err := it.pd.AddEvictScheduler(store.Id)
+		action := it.actions[it.config.Action]
+		for _, candidate := range shouldEvict {
+			if !stillLeading(ctx) {
+				return
+			}
+			store := candidate.Store
+			err := action.Apply(it.pd, store)
 			if err != nil {
-				log.L().With(zap.Error(err)).With(zap.Any("store", store)).Error("failed to evict node")
+				it.metrics.Failures.WithLabelValues(FailureEvict).Inc()
+				log.L().With(zap.Error(err)).With(zap.Any("store", store)).With(zap.String("action", action.Name())).Error("failed to evict node")
 			} else {
-				log.L().With(zap.Any("store", store)).Info("tikv node evicted")
+				if action.Name() == ActionEvictSlowStore {
+					log.L().With(zap.Any("store", store)).With(zap.String("action", action.Name())).
+						Info("tikv node handed over to evict-slow-store-scheduler, " + slowStoreNote)
+				} else {
+					log.L().With(zap.Any("store", store)).With(zap.String("action", action.Name())).Info("tikv node evicted")
+				}
+				it.metrics.Evictions.WithLabelValues(action.Name(), string(candidate.Health)).Inc()
+				it.recordFlap(store, now)
+				it.state.RecordEvicted(store, action.Name(), fmt.Sprintf("node %s is %s", candidate.Node, candidate.Health), now)
+				it.saveState()
 			}
 		}
 	}
 
 	// recover
-	if shouldRecover, err := it.findOutShouldRecover(healthMap); err != nil {
+	if shouldRecover, err := it.findOutShouldRecover(healthMap, now); err != nil {
+		it.metrics.Failures.WithLabelValues(FailureFindShouldRecover).Inc()
 		log.L().With(zap.Error(err)).Error("failed to find out should recovered stores; it will not recover any tikv nodes at this time")
 	} else {
 		for _, store := range shouldRecover {
-			err := it.pd.RemoveEvictScheduler(store.Id)
+			if !stillLeading(ctx) {
+				return
+			}
+			// revert the action which was applied, it might not be the configured one
+			err := it.actions[store.Action].Revert(it.pd, store.Store)
 			if err != nil {
+				it.metrics.Failures.WithLabelValues(FailureRecover).Inc()
 				log.L().With(zap.Error(err)).With(zap.Any("store", store)).Error("failed to recover node")
 			} else {
 				log.L().With(zap.Any("store", store)).Info("tikv node recovered")
+				it.metrics.Recoveries.WithLabelValues(store.Action, string(store.Health)).Inc()
+				it.state.RecordRecovered(store.Store, now)
+				it.saveState()
 			}
 		}
 	}
-	return nil
+
+	// lower leader weight for unstable nodes
+	if !stillLeading(ctx) {
+		return
+	}
+	if err := it.adjustLeaderWeights(ctx, healthMap); err != nil {
+		it.metrics.Failures.WithLabelValues(FailureAdjustWeight).Inc()
+		log.L().With(zap.Error(err)).Error("failed to adjust leader weights; it will not change any weights at this time")
+	}
 }
 
-func (it *Evictor) findOutShouldEvict(nodes map[string]NodeHealth) ([]pdhelper.Store, error) {
+// stillLeading returns false once ctx is done, e.g. this replica loses its leadership, then evictor should not mutate
+// pd any more in this loop.
+func stillLeading(ctx context.Context) bool {
+	if err := ctx.Err(); err != nil {
+		log.L().With(zap.Error(err)).Warn("evictor is stopped, it will not change pd in this loop")
+		return false
+	}
+	return true
+}
+
+// evictCandidate is a store which should be evicted, with the node it belongs to.
+type evictCandidate struct {
+	pdhelper.Store
+	Node     string     `json:"node"`
+	Health   NodeHealth `json:"health"`
+	Severity Severity   `json:"severity"`
+}
+
+// recoverCandidate is a store which should be recovered, with the health of its node, which is Unknown for the
+// stores recovered by UnknownRecover.
+type recoverCandidate struct {
+	mitigatedStore
+	Health NodeHealth `json:"health"`
+}
+
+// findOutShouldEvict returns the new stores to evict within the budget, the most severe ones first; the others are
+// deferred until the budget allows.
+func (it *Evictor) findOutShouldEvict(nodes map[string]NodeHealth, severities map[string]Severity) ([]evictCandidate, error) {
 	allStores, err := it.pd.ListStores()
 	if err != nil {
 		return nil, err
 	}
-	var shouldEvicts []pdhelper.Store
+	topology := it.mapper.Map(nodesOf(nodes), allStores)
+	var shouldEvicts []evictCandidate
 	for key, health := range nodes {
-		if health != Unhealthy {
+		if health != Unhealthy && !(health == Unknown && it.config.UnknownEvictPolicy == UnknownEvict) {
 			continue
 		}
-		for _, store := range allStores {
-			if strings.Contains(store.Address, key) {
-				shouldEvicts = append(shouldEvicts, store)
-			}
+		for _, store := range topology.StoresOf(key) {
+			shouldEvicts = append(shouldEvicts, evictCandidate{Store: store, Node: key, Health: health, Severity: severities[key]})
 		}
 	}
 
-	evictedStores, err := it.pd.ListEvictedStore()
-
-	// check max-evicted
-	if uint(len(evictedStores)) >= it.config.MaxEvicted {
-		log.L().With(zap.Uint("max-evicted", it.config.MaxEvicted)).With(zap.Any("already-evicted", evictedStores)).Warn("max-evicted exceed")
-		return nil, fmt.Errorf("max-evicted exceed")
+	evictedStores, err := it.getEvicted()
+	if err != nil {
+		return nil, err
 	}
 
-	var result []pdhelper.Store
-
+	var result []evictCandidate
+	// the number of bad links ranks first, since Magnitude and BadFor could outweigh a bad link in Score
+	sort.Slice(shouldEvicts, func(i, j int) bool {
+		if shouldEvicts[i].Severity.BadLinks != shouldEvicts[j].Severity.BadLinks {
+			return shouldEvicts[i].Severity.BadLinks > shouldEvicts[j].Severity.BadLinks
+		}
+		if shouldEvicts[i].Severity.Score != shouldEvicts[j].Severity.Score {
+			return shouldEvicts[i].Severity.Score > shouldEvicts[j].Severity.Score
+		}
+		return shouldEvicts[i].Id < shouldEvicts[j].Id
+	})
+	budget := newEvictionBudget(it.config, allStores, evictedStores)
 	for _, shouldEvictItem := range shouldEvicts {
 		newToEvict := true
 		for _, evictedStore := range evictedStores {
@@ -144,9 +474,18 @@ func (it *Evictor) findOutShouldEvict(nodes map[string]NodeHealth) ([]pdhelper.S
 				break
 			}
 		}
-		if newToEvict {
-			result = append(result, shouldEvictItem)
+		if !newToEvict {
+			continue
+		}
+		if err := budget.admit(shouldEvictItem.Store); err != nil {
+			if err == errMaxEvictedExceeded {
+				it.metrics.Failures.WithLabelValues(FailureMaxEvictedExceeded).Inc()
+			}
+			log.L().With(zap.Error(err)).With(zap.Any("store", shouldEvictItem)).Warn("eviction of store is deferred by the eviction budget")
+			continue
 		}
+		budget.take(shouldEvictItem.Store)
+		result = append(result, shouldEvictItem)
 	}
 	if len(result) > 0 {
 		log.L().With(zap.Any("already-evicted", evictedStores)).With(zap.Any("new-to-evicted", result)).Info("fetch new stores to evicted")
@@ -156,23 +495,44 @@ func (it *Evictor) findOutShouldEvict(nodes map[string]NodeHealth) ([]pdhelper.S
 	return result, nil
 }
 
-func (it *Evictor) findOutShouldRecover(healthMap map[string]NodeHealth) ([]pdhelper.Store, error) {
+func (it *Evictor) findOutShouldRecover(healthMap map[string]NodeHealth, now time.Time) ([]recoverCandidate, error) {
 	evictedStores, err := it.getEvicted()
 	if err != nil {
 		return nil, err
 	}
-	var newToRecover []pdhelper.Store
+	it.reconcile(evictedStores)
+	var stores []pdhelper.Store
 	for _, store := range evictedStores {
-		var ipAddress string
-		if strings.Contains(store.Address, ":") {
-			ipAddress = store.Address[:strings.LastIndex(store.Address, ":")]
-		} else {
-			ipAddress = store.Address
+		stores = append(stores, store.Store)
+	}
+	topology := it.mapper.Map(nodesOf(healthMap), stores)
+	var newToRecover []recoverCandidate
+	var foreign []mitigatedStore
+	owned := make(map[uint]bool)
+	for _, store := range evictedStores {
+		// only recover stores evicted by evictor itself; others might be evicted by operators for maintenance
+		if !it.state.Owns(store.Action, store.Id) {
+			foreign = append(foreign, store)
+			continue
+		}
+		owned[store.Id] = true
+		// the stores without any probes are Unknown as well
+		health := Unknown
+		if node, ok := topology.NodeOf(store.Store); ok {
+			health = healthMap[node]
 		}
-		if value, ok := healthMap[ipAddress]; ok && value == Healthy {
-			newToRecover = append(newToRecover, store)
+		healthy := health == Healthy || (health == Unknown && it.config.UnknownRecoverPolicy == UnknownRecover)
+		if it.heldHealthy(store.Id, healthy, now) {
+			newToRecover = append(newToRecover, recoverCandidate{mitigatedStore: store, Health: health})
+		}
+	}
+	// forget the stores which are not evicted by evictor any more
+	for storeId := range it.healthySince {
+		if !owned[storeId] {
+			delete(it.healthySince, storeId)
 		}
 	}
+	it.reportForeign(foreign)
 	if len(newToRecover) > 0 {
 		log.L().With(zap.Any("already-evicted", evictedStores)).With(zap.Any("new-to-recover", newToRecover)).Info("new stores to recover")
 	} else {
@@ -181,6 +541,84 @@ func (it *Evictor) findOutShouldRecover(healthMap map[string]NodeHealth) ([]pdhe
 	return newToRecover, nil
 }
 
+// reconcile forgets stores in state which are not mitigated on pd any more, e.g. recovered by operators manually.
+func (it *Evictor) reconcile(evicted []mitigatedStore) {
+	applied := make(map[string][]uint)
+	for _, store := range evicted {
+		applied[store.Action] = append(applied[store.Action], store.Id)
+	}
+	forgotten := it.state.Reconcile(applied, time.Now())
+	if len(forgotten) == 0 {
+		return
+	}
+	log.L().With(zap.Any("stores", forgotten)).Warn("stores evicted by evictor are not evicted on pd any more, forget them")
+	if slowStore, ok := it.actions[ActionEvictSlowStore].(*evictSlowStoreAction); ok {
+		for _, store := range forgotten {
+			slowStore.forget(store.Id)
+		}
+	}
+	it.saveState()
+}
+
+func (it *Evictor) saveState() {
+	if err := it.stateStore.Save(it.state); err != nil {
+		it.metrics.Failures.WithLabelValues(FailureSaveState).Inc()
+		log.L().With(zap.Error(err)).Error("failed to persist evictor state")
+	}
+}
+
+// reportForeign logs stores evicted by others, which are counted toward max-evicted but never recovered by evictor.
+func (it *Evictor) reportForeign(foreign []mitigatedStore) {
+	if reflect.DeepEqual(foreign, it.foreign) {
+		log.L().With(zap.Any("foreign-evicted", foreign)).Debug("stores evicted by others")
+		return
+	}
+	it.foreign = foreign
+	log.L().With(zap.Any("foreign-evicted", foreign)).Info("stores evicted by others changed, they will not be recovered by evictor")
+}
+
+type LinkState string
+
+const (
+	LinkGood     LinkState = "good"
+	LinkBad      LinkState = "bad"
+	LinkUnstable LinkState = "unstable"
+	// LinkStale has no samples, or its series stopped updating.
+	LinkStale LinkState = "stale"
+)
+
+// classifyLink treats a link as bad if its latency exceeds Threshold by EvictRule, or its loss ratio exceeds LossThreshold,
+// for PendingForEvict; and as good if its latency stays below RecoverThreshold without failures by RecoverRule for
+// PendingForRecover.
+func (it *Evictor) classifyLink(ts promhelper.TimeSeries) LinkState {
+	if ts.StaleAt(time.Now(), it.config.StaleThreshold) {
+		return LinkStale
+	}
+	if ts.LatencyLargerThanThresholdBy(it.config.EvictRule, it.config.Threshold, it.config.PendingForEvict) {
+		return LinkBad
+	}
+	if it.config.LossThreshold > 0 {
+		if ratio, ok := ts.LossRatioFor(it.config.PendingForEvict); ok && ratio*100 > it.config.LossThreshold {
+			return LinkBad
+		}
+	}
+	if ts.LatencySmallerThanThresholdBy(it.config.RecoverRule, it.config.recoverThreshold(), it.config.PendingForRecover) {
+		return LinkGood
+	}
+	return LinkUnstable
+}
+
+// classifyLinks classifies each link by classifyLink.
+func (it *Evictor) classifyLinks(metrics map[promhelper.Link]promhelper.TimeSeries) map[promhelper.Link]LinkState {
+	result := make(map[promhelper.Link]LinkState)
+	for link, ts := range metrics {
+		result[link] = it.classifyLink(ts)
+	}
+	return result
+}
+
+// generateNodeHealthMap judges each node by the bad links attributed to it by attributeBadLinks, and the unstable
+// links from and to it.
 func (it *Evictor) generateNodeHealthMap(metrics map[promhelper.Link]promhelper.TimeSeries) map[string]NodeHealth {
 	var allNodes []string
 	for link := range metrics {
@@ -191,22 +629,42 @@ func (it *Evictor) generateNodeHealthMap(metrics map[promhelper.Link]promhelper.
 			allNodes = append(allNodes, link.To)
 		}
 	}
-	var nodesWithBadLinks = make(map[string][]promhelper.Link)
-	for link, ts := range metrics {
-		if ts.LatencyLargerThanThresholdFor(it.config.Threshold, it.config.PendingForEvict) {
-			// As any one link performs as unhealthy, this node treads unhealthy.
-			// It could overwrite existed Healthy and Unstable.
-			nodesWithBadLinks[link.From] = append(nodesWithBadLinks[link.From], link)
+	states := it.classifyLinks(metrics)
+	var nodesWithFreshLinks = make(map[string]bool)
+	var nodesWithUnstableLinks = make(map[string]bool)
+	for link, state := range states {
+		if state != LinkStale {
+			nodesWithFreshLinks[link.From] = true
+			nodesWithFreshLinks[link.To] = true
+		}
+		switch state {
+		case LinkBad:
 			log.L().Debug("bad link", zap.String("from", link.From), zap.String("to", link.To))
-		} else if ts.LatencySmallerThanThresholdFor(it.config.Threshold, it.config.PendingForRecover) {
-			continue
-		} else {
+		case LinkUnstable:
+			// the latency of both ends is not settled yet
+			nodesWithUnstableLinks[link.From] = true
+			nodesWithUnstableLinks[link.To] = true
 			log.L().Debug("unstable link", zap.String("from", link.From), zap.String("to", link.To))
+		case LinkStale:
+			log.L().Debug("stale link", zap.String("from", link.From), zap.String("to", link.To))
+		}
+	}
+	var nodesWithBadLinks = make(map[string][]promhelper.Link)
+	for node, suspicion := range attributeBadLinks(states) {
+		if len(suspicion.Blamed) > 0 {
+			nodesWithBadLinks[node] = suspicion.Blamed
+		}
+		if suspicion.BadLinks > 0 {
+			log.L().Debug("suspicion of node", zap.String("node", node), zap.Float64("score", suspicion.Score),
+				zap.Bool("suspect", suspicion.Suspect), zap.String("justification", suspicion.Justification))
 		}
 	}
 	result := make(map[string]NodeHealth)
 	for _, node := range allNodes {
-		if badLinks, ok := nodesWithBadLinks[node]; ok {
+		if !nodesWithFreshLinks[node] {
+			log.L().Debug("there are no fresh samples about node, treated as Unknown", zap.String("node", node))
+			result[node] = Unknown
+		} else if badLinks, ok := nodesWithBadLinks[node]; ok {
 			badLinkNum := uint(len(badLinks))
 			if badLinkNum == 0 {
 				log.L().Debug("bad link about node not exist, treated as Healthy",
@@ -225,6 +683,10 @@ func (it *Evictor) generateNodeHealthMap(metrics map[promhelper.Link]promhelper.
 					zap.Any("bad links", badLinks))
 				result[node] = Unhealthy
 			}
+		} else if nodesWithUnstableLinks[node] {
+			log.L().Debug("there are no bad link about node, but some links are not good yet, treated as Recovering",
+				zap.String("node", node))
+			result[node] = Recovering
 		} else {
 			log.L().Debug("there are no bad link about node, treated as Healthy", zap.String("node", node))
 			result[node] = Healthy
@@ -233,8 +695,27 @@ func (it *Evictor) generateNodeHealthMap(metrics map[promhelper.Link]promhelper.
 	return result
 }
 
-func (it *Evictor) getEvicted() ([]pdhelper.Store, error) {
-	return it.pd.ListEvictedStore()
+// getEvicted returns stores mitigated by any action, so stores could be recovered after the action is reconfigured.
+func (it *Evictor) getEvicted() ([]mitigatedStore, error) {
+	var result []mitigatedStore
+	for name, action := range it.actions {
+		stores, err := action.ListApplied(it.pd)
+		if err != nil {
+			return nil, err
+		}
+		for _, store := range stores {
+			result = append(result, mitigatedStore{Store: store, Action: name})
+		}
+	}
+	return result, nil
+}
+
+func nodesOf(healthMap map[string]NodeHealth) []string {
+	var result []string
+	for node := range healthMap {
+		result = append(result, node)
+	}
+	return result
 }
 
 func contains(array []string, target string) bool {
@@ -0,0 +1,383 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvictor_ExactNodeMatching(t *testing.T) {
+	pd := newFakeExecutor(
+		pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"},
+		pdhelper.Store{Id: 11, Address: "10.0.0.11:20160"},
+		pdhelper.Store{Id: 100, Address: "10.0.0.100:20160"},
+	)
+	evictor := newTestEvictor(Config{MaxEvicted: 3}, pd)
+
+	healthMap := map[string]NodeHealth{"10.0.0.1": Unhealthy, "10.0.0.11": Healthy, "10.0.0.100": Healthy}
+	shouldEvict, err := evictor.findOutShouldEvict(healthMap, nil)
+	if err != nil {
+		t.Fatalf("findOutShouldEvict() error = %v", err)
+	}
+	want := []evictCandidate{{Store: pd.stores[0], Node: "10.0.0.1", Health: Unhealthy}}
+	if !reflect.DeepEqual(shouldEvict, want) {
+		t.Errorf("findOutShouldEvict() = %v, want %v", shouldEvict, want)
+	}
+
+	// store 11 is evicted while its node is healthy, but store 1 on unhealthy 10.0.0.1 must stay evicted
+	for _, store := range []pdhelper.Store{pd.stores[0], pd.stores[1]} {
+		_ = pd.AddEvictScheduler(store.Id)
+		evictor.state.RecordEvicted(store, ActionEvictLeader, "test", time.Now())
+	}
+	shouldRecover, err := evictor.findOutShouldRecover(healthMap, time.Now())
+	if err != nil {
+		t.Fatalf("findOutShouldRecover() error = %v", err)
+	}
+	if got, want := recoverIds(shouldRecover), []string{"evict-leader/11"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findOutShouldRecover() = %v, want %v", got, want)
+	}
+}
+
+func TestEvictor_findOutShouldEvictAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    string
+		stores  []pdhelper.Store
+		wantIds []uint
+	}{
+		{
+			name:    "ipv4",
+			node:    "10.0.0.1",
+			stores:  []pdhelper.Store{{Id: 1, Address: "10.0.0.1:20160"}, {Id: 2, Address: "10.0.0.10:20160"}},
+			wantIds: []uint{1},
+		}, {
+			name:    "ipv6",
+			node:    "2001:db8::1",
+			stores:  []pdhelper.Store{{Id: 1, Address: "[2001:db8::1]:20160"}, {Id: 2, Address: "[2001:db8::10]:20160"}},
+			wantIds: []uint{1},
+		}, {
+			name:    "hostname",
+			node:    "tikv-0",
+			stores:  []pdhelper.Store{{Id: 1, Address: "tikv-0:20160"}, {Id: 2, Address: "tikv-00:20160"}},
+			wantIds: []uint{1},
+		}, {
+			name:    "store without port",
+			node:    "2001:db8::1",
+			stores:  []pdhelper.Store{{Id: 1, Address: "2001:db8::1"}, {Id: 2, Address: "[2001:db8::1:0]:20160"}},
+			wantIds: []uint{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictor := newTestEvictor(Config{MaxEvicted: 2}, newFakeExecutor(tt.stores...))
+			shouldEvict, err := evictor.findOutShouldEvict(map[string]NodeHealth{tt.node: Unhealthy}, nil)
+			if err != nil {
+				t.Fatalf("findOutShouldEvict() error = %v", err)
+			}
+			var got []uint
+			for _, candidate := range shouldEvict {
+				got = append(got, candidate.Id)
+			}
+			if !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("findOutShouldEvict() = %v, want %v", got, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestEvictor_classifyLink(t *testing.T) {
+	now := time.Now()
+	// one sample every 15s for 2 minutes, failed if the index is in failed
+	series := func(latency time.Duration, failed ...int) promhelper.TimeSeries {
+		var result promhelper.TimeSeries
+		for i := 0; i <= 8; i++ {
+			sample := promhelper.Sample{Timestamp: now.Add(time.Duration(i-8) * 15 * time.Second), Latency: latency}
+			for _, index := range failed {
+				if index == i {
+					sample.Failed = true
+				}
+			}
+			result = append(result, sample)
+		}
+		return result
+	}
+	// 80% of samples in the window
+	fraction := promhelper.Rule{Mode: promhelper.RuleFraction, Value: 80}
+	tests := []struct {
+		name          string
+		lossThreshold float64
+		rule          promhelper.Rule
+		ts            promhelper.TimeSeries
+		want          LinkState
+	}{
+		{"fast", 0, promhelper.Rule{}, series(time.Millisecond), LinkGood},
+		{"slow", 0, promhelper.Rule{}, series(2 * time.Second), LinkBad},
+		{"all failed but fast", 0, promhelper.Rule{}, series(time.Millisecond, 0, 1, 2, 3, 4, 5, 6, 7, 8), LinkBad},
+		{"partial loss without loss threshold", 0, promhelper.Rule{}, series(time.Millisecond, 5, 7), LinkUnstable},
+		{"partial loss over loss threshold", 20, promhelper.Rule{}, series(time.Millisecond, 5, 7), LinkBad},
+		{"partial loss under loss threshold", 50, promhelper.Rule{}, series(time.Millisecond, 5, 7), LinkUnstable},
+		{"loss out of pending for evict", 20, promhelper.Rule{}, series(time.Millisecond, 0, 1), LinkUnstable},
+		{"mostly failed by fraction", 0, fraction, series(time.Millisecond, 4, 5, 6, 7), LinkBad},
+		{"one failure by fraction", 0, fraction, series(time.Millisecond, 5), LinkGood},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictor := newTestEvictor(Config{
+				Threshold:         time.Second,
+				LossThreshold:     tt.lossThreshold,
+				EvictRule:         tt.rule,
+				RecoverRule:       tt.rule,
+				PendingForEvict:   time.Minute,
+				PendingForRecover: 2 * time.Minute,
+			}, newFakeExecutor())
+			if got := evictor.classifyLink(tt.ts); got != tt.want {
+				t.Errorf("classifyLink() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictor_generateNodeHealthMapUnknown(t *testing.T) {
+	now := time.Now()
+	series := func(latency time.Duration, end time.Time) promhelper.TimeSeries {
+		var result promhelper.TimeSeries
+		for i := -8; i <= 0; i++ {
+			result = append(result, promhelper.Sample{Timestamp: end.Add(time.Duration(i) * 15 * time.Second), Latency: latency})
+		}
+		return result
+	}
+	evictor := newTestEvictor(Config{
+		Threshold:            time.Second,
+		BadLinkFuseThreshold: 1,
+		PendingForEvict:      time.Minute,
+		PendingForRecover:    time.Minute,
+		StaleThreshold:       time.Minute,
+	}, newFakeExecutor())
+
+	got := evictor.generateNodeHealthMap(map[promhelper.Link]promhelper.TimeSeries{
+		{From: "10.0.0.1", To: "10.0.0.2"}: series(time.Millisecond, now),
+		{From: "10.0.0.2", To: "10.0.0.1"}: series(time.Millisecond, now),
+		// probes from and to 10.0.0.3 stopped updating, though they were slow
+		{From: "10.0.0.3", To: "10.0.0.1"}: series(2*time.Second, now.Add(-10*time.Minute)),
+		{From: "10.0.0.1", To: "10.0.0.3"}: series(2*time.Second, now.Add(-10*time.Minute)),
+		{From: "10.0.0.4", To: "10.0.0.1"}: nil,
+	})
+	want := map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Healthy, "10.0.0.3": Unknown, "10.0.0.4": Unknown}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generateNodeHealthMap() = %v, want %v", got, want)
+	}
+}
+
+func TestEvictor_UnknownPolicies(t *testing.T) {
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "10.0.0.1:20160"},
+		{Id: 2, Address: "10.0.0.2:20160"},
+		{Id: 3, Address: "10.0.0.3:20160"},
+	}
+	// 10.0.0.2 is unknown, and there are no probes about 10.0.0.3 at all
+	healthMap := map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Unknown}
+	tests := []struct {
+		name          string
+		evictPolicy   string
+		recoverPolicy string
+		wantEvict     []uint
+		wantRecover   []string
+	}{
+		{
+			name:        "default",
+			wantEvict:   nil,
+			wantRecover: []string{"evict-leader/1"},
+		}, {
+			name:          "keep and ignore",
+			evictPolicy:   UnknownIgnore,
+			recoverPolicy: UnknownKeep,
+			wantEvict:     nil,
+			wantRecover:   []string{"evict-leader/1"},
+		}, {
+			name:          "evict and recover",
+			evictPolicy:   UnknownEvict,
+			recoverPolicy: UnknownRecover,
+			wantEvict:     []uint{2},
+			wantRecover:   []string{"evict-leader/1", "evict-leader/2", "evict-leader/3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pd := newFakeExecutor(stores...)
+			evictor := newTestEvictor(Config{MaxEvicted: 3, UnknownEvictPolicy: tt.evictPolicy, UnknownRecoverPolicy: tt.recoverPolicy}, pd)
+
+			shouldEvict, err := evictor.findOutShouldEvict(healthMap, nil)
+			if err != nil {
+				t.Fatalf("findOutShouldEvict() error = %v", err)
+			}
+			var evictIds []uint
+			for _, candidate := range shouldEvict {
+				evictIds = append(evictIds, candidate.Id)
+			}
+			if !reflect.DeepEqual(evictIds, tt.wantEvict) {
+				t.Errorf("findOutShouldEvict() = %v, want %v", evictIds, tt.wantEvict)
+			}
+
+			for _, store := range stores {
+				_ = pd.AddEvictScheduler(store.Id)
+				evictor.state.RecordEvicted(store, ActionEvictLeader, "test", time.Now())
+			}
+			shouldRecover, err := evictor.findOutShouldRecover(healthMap, time.Now())
+			if err != nil {
+				t.Fatalf("findOutShouldRecover() error = %v", err)
+			}
+			if got := recoverIds(shouldRecover); !reflect.DeepEqual(got, tt.wantRecover) {
+				t.Errorf("findOutShouldRecover() = %v, want %v", got, tt.wantRecover)
+			}
+			// the stores recovered by the unknown recover policy are labeled unknown in metrics
+			for _, candidate := range shouldRecover {
+				want, ok := healthMap[addrhelper.HostOf(candidate.Address)]
+				if !ok {
+					want = Unknown
+				}
+				if candidate.Health != want {
+					t.Errorf("health of recovered store %d = %v, want %v", candidate.Id, candidate.Health, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvictor_Flapping(t *testing.T) {
+	start := time.Now()
+	// the latency of probes from 10.0.0.2 alternates every 90s, while probes from 10.0.0.1 are always fast
+	alternate := func(high, low time.Duration) func(time.Duration) time.Duration {
+		return func(elapsed time.Duration) time.Duration {
+			if (elapsed/(90*time.Second))%2 == 0 {
+				return high
+			}
+			return low
+		}
+	}
+	tests := []struct {
+		name             string
+		recoverThreshold time.Duration
+		latency          func(time.Duration) time.Duration
+		wantEvictions    int
+		wantEvicted      bool
+	}{
+		{"hovering around the threshold", 0, alternate(1200*time.Millisecond, 900*time.Millisecond), 3, false},
+		{"hovering above the recover threshold", 800 * time.Millisecond, alternate(1200*time.Millisecond, 900*time.Millisecond), 1, true},
+		{"recovered below the recover threshold", 800 * time.Millisecond, alternate(1200*time.Millisecond, 500*time.Millisecond), 3, false},
+		{"short spikes", 800 * time.Millisecond, func(elapsed time.Duration) time.Duration {
+			if elapsed%time.Minute == 0 {
+				return 2 * time.Second
+			}
+			return 10 * time.Millisecond
+		}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Action:               ActionEvictLeader,
+				MaxEvicted:           1,
+				Threshold:            time.Second,
+				RecoverThreshold:     tt.recoverThreshold,
+				BadLinkFuseThreshold: 1,
+				PendingForEvict:      time.Minute,
+				PendingForRecover:    time.Minute,
+			}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			pd := newFakeExecutor(pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}, pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"})
+			evictor := newTestEvictor(config, pd)
+
+			var slow, fast promhelper.TimeSeries
+			var evictions int
+			// one sample and one loop every 15s for 10 minutes
+			for elapsed := time.Duration(0); elapsed < 10*time.Minute; elapsed += 15 * time.Second {
+				now := start.Add(elapsed)
+				slow = append(slow, promhelper.Sample{Timestamp: now, Latency: tt.latency(elapsed)})
+				fast = append(fast, promhelper.Sample{Timestamp: now, Latency: 10 * time.Millisecond})
+				wasEvicted := pd.evicted[2]
+				metrics := map[promhelper.Link]promhelper.TimeSeries{
+					{From: "10.0.0.2", To: "10.0.0.1"}: slow,
+					{From: "10.0.0.1", To: "10.0.0.2"}: fast,
+				}
+				evictor.mitigate(context.Background(), evictor.generateNodeHealthMap(metrics), evictor.severities(metrics), now)
+				if pd.evicted[1] {
+					t.Fatalf("store 1 should never be evicted, elapsed %v", elapsed)
+				}
+				if !wasEvicted && pd.evicted[2] {
+					evictions++
+				}
+			}
+			if evictions != tt.wantEvictions {
+				t.Errorf("evictions = %v, want %v", evictions, tt.wantEvictions)
+			}
+			if pd.evicted[2] != tt.wantEvicted {
+				t.Errorf("evicted = %v, want %v", pd.evicted[2], tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestEvictor_mitigateAfterLeadershipLost(t *testing.T) {
+	healthMap := map[string]NodeHealth{"10.0.0.1": Unhealthy, "10.0.0.2": Healthy}
+	tests := []struct {
+		name        string
+		canceled    bool
+		wantEvicted bool
+	}{
+		{"leading", false, true},
+		{"leadership lost", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pd := newFakeExecutor(pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}, pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"})
+			evictor := newTestEvictor(Config{Action: ActionEvictLeader, MaxEvicted: 1}, pd)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.canceled {
+				cancel()
+			}
+			evictor.mitigate(ctx, healthMap, nil, time.Now())
+			cancel()
+			if pd.evicted[1] != tt.wantEvicted {
+				t.Errorf("evicted = %v, want %v", pd.evicted[1], tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestRetryStartup(t *testing.T) {
+	startupBackoff = time.Millisecond
+	defer func() { startupBackoff = time.Second }()
+	unreachable := fmt.Errorf("connection refused")
+	invalid := fmt.Errorf("invalid")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds at once", nil, 1, nil},
+		{"succeeds after retries", []error{unreachable, unreachable}, 3, nil},
+		{"not retryable", []error{invalid, unreachable}, 1, invalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryStartup("check", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}, func(err error) bool { return err != invalid })
+			if err != tt.wantErr || calls != tt.wantCalls {
+				t.Errorf("retryStartup() = %v after %d calls, want %v after %d calls", err, calls, tt.wantErr, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/state"
+	"fmt"
+	"time"
+)
+
+// newTestEvictor creates an Evictor on the fake executor, which keeps its state in memory.
+func newTestEvictor(config Config, pd *fakeExecutor) *Evictor {
+	current := state.NewState()
+	return &Evictor{
+		config:       config,
+		pd:           pd,
+		actions:      newActions(current),
+		stateStore:   &state.MemoryStore{},
+		state:        current,
+		metrics:      NewMetrics(),
+		mapper:       addrhelper.NewMapper(addrhelper.StaticResolver{}, nil),
+		healthySince: make(map[uint]time.Time),
+	}
+}
+
+// fakeExecutor keeps the state of a pd cluster in memory.
+type fakeExecutor struct {
+	stores          []pdhelper.Store
+	evicted         map[uint]bool
+	slowStoreActive bool
+	weights         map[uint]pdhelper.StoreWeight
+}
+
+func newFakeExecutor(stores ...pdhelper.Store) *fakeExecutor {
+	return &fakeExecutor{
+		stores:  stores,
+		evicted: make(map[uint]bool),
+		weights: make(map[uint]pdhelper.StoreWeight),
+	}
+}
+
+func (it *fakeExecutor) AddEvictScheduler(storeId uint) error {
+	it.evicted[storeId] = true
+	return nil
+}
+
+func (it *fakeExecutor) RemoveEvictScheduler(storeId uint) error {
+	if !it.evicted[storeId] {
+		return fmt.Errorf("scheduler not found")
+	}
+	delete(it.evicted, storeId)
+	return nil
+}
+
+func (it *fakeExecutor) ListStores() ([]pdhelper.Store, error) {
+	return it.stores, nil
+}
+
+func (it *fakeExecutor) ListEvictedStore() ([]pdhelper.Store, error) {
+	var result []pdhelper.Store
+	for _, store := range it.stores {
+		if it.evicted[store.Id] {
+			result = append(result, store)
+		}
+	}
+	return result, nil
+}
+
+func (it *fakeExecutor) AddEvictSlowStoreScheduler() error {
+	it.slowStoreActive = true
+	return nil
+}
+
+func (it *fakeExecutor) RemoveEvictSlowStoreScheduler() error {
+	it.slowStoreActive = false
+	return nil
+}
+
+func (it *fakeExecutor) HasEvictSlowStoreScheduler() (bool, error) {
+	return it.slowStoreActive, nil
+}
+
+func (it *fakeExecutor) GetStoreWeight(storeId uint) (pdhelper.StoreWeight, error) {
+	if weight, ok := it.weights[storeId]; ok {
+		return weight, nil
+	}
+	return pdhelper.StoreWeight{Leader: 1, Region: 1}, nil
+}
+
+func (it *fakeExecutor) SetStoreWeight(storeId uint, weight pdhelper.StoreWeight) error {
+	it.weights[storeId] = weight
+	return nil
+}
@@ -0,0 +1,76 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"go.uber.org/zap"
+	"math"
+	"time"
+)
+
+// recordFlap counts a flap if the store is evicted again within FlapWindow after recovered by evictor.
+func (it *Evictor) recordFlap(store pdhelper.Store, now time.Time) {
+	if it.config.FlapWindow <= 0 {
+		return
+	}
+	known, ok := it.state.Stores[store.Id]
+	if !ok || known.RecoveredAt.IsZero() || now.Sub(known.RecoveredAt) > it.config.FlapWindow {
+		return
+	}
+	it.state.RecordFlapped(store, it.config.FlapDecay, now)
+	it.metrics.Flaps.Inc()
+	log.L().With(zap.Any("store", store)).With(zap.Uint("flaps", known.Flaps)).
+		With(zap.Duration("recover-hold", it.recoverHold(store.Id, now))).
+		Warn("tikv node is flapping, it will keep evicted longer")
+}
+
+// recoverHold returns how long an evicted store should keep healthy before recovered: 0 without flaps, otherwise
+// the larger of PendingForRecover and Interval doubled by each flap, capped at FlapMaxHold, or saturated
+// without it.
+func (it *Evictor) recoverHold(storeId uint, now time.Time) time.Duration {
+	known, ok := it.state.Stores[storeId]
+	if !ok {
+		return 0
+	}
+	flaps := known.FlapsAt(now, it.config.FlapDecay)
+	if flaps == 0 {
+		return 0
+	}
+	// starts from Interval at least, so flaps still hold the store with PendingForRecover of 0
+	hold := it.config.PendingForRecover
+	if hold < it.config.Interval {
+		hold = it.config.Interval
+	}
+	for i := uint(0); i < flaps; i++ {
+		if hold > math.MaxInt64/2 {
+			// saturate instead of overflowing without FlapMaxHold
+			return time.Duration(math.MaxInt64)
+		}
+		hold *= 2
+		if it.config.FlapMaxHold > 0 && hold >= it.config.FlapMaxHold {
+			return it.config.FlapMaxHold
+		}
+	}
+	return hold
+}
+
+// heldHealthy reports whether the evicted store has kept healthy for its recoverHold, which is tracked across loops;
+// healthy is whether the store could be recovered in this loop.
+func (it *Evictor) heldHealthy(storeId uint, healthy bool, now time.Time) bool {
+	if !healthy {
+		delete(it.healthySince, storeId)
+		return false
+	}
+	since, ok := it.healthySince[storeId]
+	if !ok {
+		since = now
+		it.healthySince[storeId] = since
+	}
+	hold := it.recoverHold(storeId, now)
+	if now.Sub(since) < hold {
+		log.L().With(zap.Uint("store", storeId)).With(zap.Time("healthy-since", since)).With(zap.Duration("recover-hold", hold)).
+			Debug("tikv node is healthy, but not for its recover hold yet")
+		return false
+	}
+	return true
+}
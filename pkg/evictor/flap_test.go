@@ -0,0 +1,110 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEvictor_recoverHold(t *testing.T) {
+	now := time.Now()
+	store := pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}
+	tests := []struct {
+		name              string
+		flaps             int
+		pendingForRecover time.Duration
+		maxHold           time.Duration
+		want              time.Duration
+	}{
+		{"no flaps", 0, time.Minute, 10 * time.Minute, 0},
+		{"one flap", 1, time.Minute, 10 * time.Minute, 2 * time.Minute},
+		{"three flaps", 3, time.Minute, 10 * time.Minute, 8 * time.Minute},
+		{"capped", 5, time.Minute, 10 * time.Minute, 10 * time.Minute},
+		{"saturated without cap", 64, time.Minute, 0, time.Duration(math.MaxInt64)},
+		{"no pending for recover", 1, 0, 10 * time.Minute, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictor := newTestEvictor(Config{Interval: 15 * time.Second, PendingForRecover: tt.pendingForRecover, FlapMaxHold: tt.maxHold,
+				FlapDecay: time.Hour}, newFakeExecutor())
+			for i := 0; i < tt.flaps; i++ {
+				evictor.state.RecordFlapped(store, time.Hour, now)
+			}
+			if got := evictor.recoverHold(store.Id, now); got != tt.want {
+				t.Errorf("recoverHold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictor_FlapDampening(t *testing.T) {
+	start := time.Now()
+	// probes from 10.0.0.2 alternate between slow and fast every 90s for 15 minutes, then they are fast
+	latency := func(elapsed time.Duration) time.Duration {
+		if elapsed < 15*time.Minute && (elapsed/(90*time.Second))%2 == 0 {
+			return 2 * time.Second
+		}
+		return 10 * time.Millisecond
+	}
+	tests := []struct {
+		name          string
+		flapWindow    time.Duration
+		wantEvictions int
+		wantFlaps     uint
+		// wantRecovered is when store 2 is recovered at last, it keeps healthy since 14m30s
+		wantRecovered time.Duration
+	}{
+		{"without dampening", 0, 5, 0, 14*time.Minute + 30*time.Second},
+		{"dampened", 30 * time.Minute, 2, 1, 16*time.Minute + 30*time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Action:               ActionEvictLeader,
+				MaxEvicted:           1,
+				Threshold:            time.Second,
+				BadLinkFuseThreshold: 1,
+				PendingForEvict:      time.Minute,
+				PendingForRecover:    time.Minute,
+				FlapWindow:           tt.flapWindow,
+				FlapMaxHold:          10 * time.Minute,
+				FlapDecay:            time.Hour,
+			}
+			pd := newFakeExecutor(pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}, pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"})
+			evictor := newTestEvictor(config, pd)
+
+			var slow, fast promhelper.TimeSeries
+			var evictions int
+			var recovered time.Duration
+			for elapsed := time.Duration(0); elapsed < 30*time.Minute; elapsed += 15 * time.Second {
+				now := start.Add(elapsed)
+				slow = append(slow, promhelper.Sample{Timestamp: now, Latency: latency(elapsed)})
+				fast = append(fast, promhelper.Sample{Timestamp: now, Latency: 10 * time.Millisecond})
+				wasEvicted := pd.evicted[2]
+				metrics := map[promhelper.Link]promhelper.TimeSeries{
+					{From: "10.0.0.2", To: "10.0.0.1"}: slow,
+					{From: "10.0.0.1", To: "10.0.0.2"}: fast,
+				}
+				evictor.mitigate(context.Background(), evictor.generateNodeHealthMap(metrics), evictor.severities(metrics), now)
+				if !wasEvicted && pd.evicted[2] {
+					evictions++
+				}
+				if wasEvicted && !pd.evicted[2] {
+					recovered = elapsed
+				}
+			}
+			if evictions != tt.wantEvictions {
+				t.Errorf("evictions = %v, want %v", evictions, tt.wantEvictions)
+			}
+			if got := evictor.state.Stores[2].Flaps; got != tt.wantFlaps {
+				t.Errorf("flaps = %v, want %v", got, tt.wantFlaps)
+			}
+			if pd.evicted[2] || recovered != tt.wantRecovered {
+				t.Errorf("recovered at %v (evicted %v), want %v", recovered, pd.evicted[2], tt.wantRecovered)
+			}
+		})
+	}
+}
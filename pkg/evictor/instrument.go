@@ -0,0 +1,83 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"time"
+)
+
+// instrumentedExecutor observes the duration of each call to pd.
+type instrumentedExecutor struct {
+	pd      pdhelper.Executor
+	metrics *Metrics
+}
+
+func instrumentExecutor(pd pdhelper.Executor, metrics *Metrics) pdhelper.Executor {
+	return &instrumentedExecutor{pd: pd, metrics: metrics}
+}
+
+func (it *instrumentedExecutor) observe(method string, start time.Time, err error) {
+	it.metrics.PdCallDuration.WithLabelValues(method, resultOf(err)).Observe(time.Since(start).Seconds())
+}
+
+func (it *instrumentedExecutor) AddEvictScheduler(storeId uint) error {
+	start := time.Now()
+	err := it.pd.AddEvictScheduler(storeId)
+	it.observe("add_evict_scheduler", start, err)
+	return err
+}
+
+func (it *instrumentedExecutor) RemoveEvictScheduler(storeId uint) error {
+	start := time.Now()
+	err := it.pd.RemoveEvictScheduler(storeId)
+	it.observe("remove_evict_scheduler", start, err)
+	return err
+}
+
+func (it *instrumentedExecutor) ListStores() ([]pdhelper.Store, error) {
+	start := time.Now()
+	result, err := it.pd.ListStores()
+	it.observe("list_stores", start, err)
+	return result, err
+}
+
+func (it *instrumentedExecutor) ListEvictedStore() ([]pdhelper.Store, error) {
+	start := time.Now()
+	result, err := it.pd.ListEvictedStore()
+	it.observe("list_evicted_store", start, err)
+	return result, err
+}
+
+func (it *instrumentedExecutor) AddEvictSlowStoreScheduler() error {
+	start := time.Now()
+	err := it.pd.AddEvictSlowStoreScheduler()
+	it.observe("add_evict_slow_store_scheduler", start, err)
+	return err
+}
+
+func (it *instrumentedExecutor) RemoveEvictSlowStoreScheduler() error {
+	start := time.Now()
+	err := it.pd.RemoveEvictSlowStoreScheduler()
+	it.observe("remove_evict_slow_store_scheduler", start, err)
+	return err
+}
+
+func (it *instrumentedExecutor) HasEvictSlowStoreScheduler() (bool, error) {
+	start := time.Now()
+	result, err := it.pd.HasEvictSlowStoreScheduler()
+	it.observe("has_evict_slow_store_scheduler", start, err)
+	return result, err
+}
+
+func (it *instrumentedExecutor) GetStoreWeight(storeId uint) (pdhelper.StoreWeight, error) {
+	start := time.Now()
+	result, err := it.pd.GetStoreWeight(storeId)
+	it.observe("get_store_weight", start, err)
+	return result, err
+}
+
+func (it *instrumentedExecutor) SetStoreWeight(storeId uint, weight pdhelper.StoreWeight) error {
+	start := time.Now()
+	err := it.pd.SetStoreWeight(storeId, weight)
+	it.observe("set_store_weight", start, err)
+	return err
+}
@@ -0,0 +1,206 @@
+package evictor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const metricsNamespace = "evictor"
+
+// The reasons of failures counted by Metrics.Failures.
+const (
+	FailureFetchMetrics       = "fetch-metrics"
+	FailureFindShouldEvict    = "find-should-evict"
+	FailureMaxEvictedExceeded = "max-evicted-exceeded"
+	FailureEvict              = "evict"
+	FailureFindShouldRecover  = "find-should-recover"
+	FailureRecover            = "recover"
+	FailureAdjustWeight       = "adjust-weight"
+	FailureSaveState          = "save-state"
+)
+
+// Metrics is the prometheus metrics of evictor itself, which are registered in Registry.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	NodeHealth          *prometheus.GaugeVec
+	LinkBad             *prometheus.GaugeVec
+	LinkUnstable        *prometheus.GaugeVec
+	NodeSuspicion       *prometheus.GaugeVec
+	Evicted             prometheus.Gauge
+	MaxEvicted          prometheus.Gauge
+	Degraded            prometheus.Gauge
+	DegradedTransitions prometheus.Counter
+	Evictions           *prometheus.CounterVec
+	Flaps               prometheus.Counter
+	StoreFlaps          *prometheus.GaugeVec
+	Recoveries          *prometheus.CounterVec
+	Failures            *prometheus.CounterVec
+	LoopDuration        prometheus.Histogram
+	PdCallDuration      *prometheus.HistogramVec
+	PromQueryDuration   *prometheus.HistogramVec
+	Leader              prometheus.Gauge
+	LeaderTransitions   prometheus.Counter
+}
+
+func NewMetrics() *Metrics {
+	it := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		NodeHealth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "node_health",
+			Help:      "Health of each node, 1 for the current state.",
+		}, []string{"node", "state"}),
+		LinkBad: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "link_bad",
+			Help:      "Whether the link is bad.",
+		}, []string{"from", "to"}),
+		LinkUnstable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "link_unstable",
+			Help:      "Whether the link is unstable.",
+		}, []string{"from", "to"}),
+		NodeSuspicion: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "node_suspicion",
+			Help:      "Ratio of bad links in the fresh links from and to the node, labeled whether it is suspected to cause them.",
+		}, []string{"node", "suspect"}),
+		Evicted: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "evicted_stores",
+			Help:      "Number of currently evicted stores, including the stores evicted by others.",
+		}),
+		MaxEvicted: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "max_evicted_stores",
+			Help:      "Max number of evicted stores.",
+		}),
+		Degraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "degraded",
+			Help:      "Whether evictor is in the degraded mode, in which it neither evicts nor recovers any stores.",
+		}),
+		DegradedTransitions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "degraded_total",
+			Help:      "Number of times entering the degraded mode.",
+		}),
+		Evictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "evictions_total",
+			Help:      "Number of evictions.",
+		}, []string{"action", "reason"}),
+		Flaps: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "flaps_total",
+			Help:      "Number of stores evicted again soon after recovered.",
+		}),
+		StoreFlaps: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_flaps",
+			Help:      "Current flaps of each evicted store, which keeps it evicted longer.",
+		}, []string{"store", "address"}),
+		Recoveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "recoveries_total",
+			Help:      "Number of recoveries.",
+		}, []string{"action", "reason"}),
+		Failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "failures_total",
+			Help:      "Number of failures.",
+		}, []string{"reason"}),
+		LoopDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "loop_duration_seconds",
+			Help:      "Duration of each loop.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}),
+		PdCallDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "pd_call_duration_seconds",
+			Help:      "Duration of operating pd, by pd-ctl or http.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"method", "result"}),
+		PromQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "prometheus_query_duration_seconds",
+			Help:      "Duration of querying prometheus.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"query", "result"}),
+		Leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "leader",
+			Help:      "Whether this replica is the leader; always 1 if leader election is disabled.",
+		}),
+		LeaderTransitions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "leader_transitions_total",
+			Help:      "Number of leadership changes of this replica.",
+		}),
+	}
+	it.Registry.MustRegister(
+		it.NodeHealth, it.LinkBad, it.LinkUnstable, it.NodeSuspicion, it.Evicted, it.MaxEvicted,
+		it.Degraded, it.DegradedTransitions,
+		it.Evictions, it.Flaps, it.StoreFlaps, it.Recoveries, it.Failures,
+		it.LoopDuration, it.PdCallDuration, it.PromQueryDuration,
+		it.Leader, it.LeaderTransitions,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	it.Leader.Set(1)
+	return it
+}
+
+// ObserveLeadership is intended to be the OnChange hook of election.Elector.
+func (it *Metrics) ObserveLeadership(leader bool) {
+	if leader {
+		it.Leader.Set(1)
+	} else {
+		it.Leader.Set(0)
+	}
+	it.LeaderTransitions.Inc()
+}
+
+// observeStatus refreshes the gauges from the snapshot of the last loop.
+func (it *Metrics) observeStatus(status Status) {
+	it.NodeHealth.Reset()
+	for node, health := range status.Nodes {
+		for _, state := range []NodeHealth{Healthy, Recovering, Unstable, Unhealthy, Unknown} {
+			it.NodeHealth.WithLabelValues(node, string(state)).Set(flag(health == state))
+		}
+	}
+	it.LinkBad.Reset()
+	it.LinkUnstable.Reset()
+	for _, link := range status.Links {
+		it.LinkBad.WithLabelValues(link.From, link.To).Set(flag(link.State == LinkBad))
+		it.LinkUnstable.WithLabelValues(link.From, link.To).Set(flag(link.State == LinkUnstable))
+	}
+	it.NodeSuspicion.Reset()
+	for node, suspicion := range status.Suspicions {
+		it.NodeSuspicion.WithLabelValues(node, strconv.FormatBool(suspicion.Suspect)).Set(suspicion.Score)
+	}
+	it.StoreFlaps.Reset()
+	for _, store := range status.Evicted {
+		if store.Flaps > 0 {
+			it.StoreFlaps.WithLabelValues(strconv.FormatUint(uint64(store.Id), 10), store.Address).Set(float64(store.Flaps))
+		}
+	}
+	it.Evicted.Set(float64(len(status.Evicted)))
+	it.MaxEvicted.Set(float64(status.Config.MaxEvicted))
+}
+
+func resultOf(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func flag(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
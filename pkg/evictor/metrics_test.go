@@ -0,0 +1,81 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+)
+
+func countSeries(collector prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+	count := 0
+	for range ch {
+		count++
+	}
+	return count
+}
+
+func TestMetrics_observeStatus(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.observeStatus(Status{
+		Nodes: map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Unstable},
+		Links: []LinkStatus{
+			{From: "10.0.0.1", To: "10.0.0.2", State: LinkGood},
+			{From: "10.0.0.2", To: "10.0.0.1", State: LinkUnstable},
+		},
+		Evicted: []EvictedStatus{{Store: pdhelper.Store{Id: 1}}},
+		Config:  Config{MaxEvicted: 2},
+	})
+	// 10.0.0.2 becomes healthy and 10.0.0.1 disappears
+	metrics.observeStatus(Status{
+		Nodes:  map[string]NodeHealth{"10.0.0.2": Healthy},
+		Config: Config{MaxEvicted: 2},
+	})
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"node health series", float64(countSeries(metrics.NodeHealth)), 5},
+		{"healthy node", testutil.ToFloat64(metrics.NodeHealth.WithLabelValues("10.0.0.2", string(Healthy))), 1},
+		{"unstable flag of healthy node", testutil.ToFloat64(metrics.NodeHealth.WithLabelValues("10.0.0.2", string(Unstable))), 0},
+		{"link series", float64(countSeries(metrics.LinkUnstable)), 0},
+		{"evicted", testutil.ToFloat64(metrics.Evicted), 0},
+		{"max evicted", testutil.ToFloat64(metrics.MaxEvicted), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentedExecutor(t *testing.T) {
+	metrics := NewMetrics()
+	pd := instrumentExecutor(newFakeExecutor(pdhelper.Store{Id: 1}), metrics)
+
+	_ = pd.AddEvictScheduler(1)
+	if err := pd.RemoveEvictScheduler(2); err == nil {
+		t.Fatalf("RemoveEvictScheduler() should fail")
+	}
+	if got := countSeries(metrics.PdCallDuration); got != 2 {
+		t.Errorf("series of pd call duration = %d, want 2", got)
+	}
+
+	metrics.ObserveLeadership(false)
+	metrics.ObserveLeadership(true)
+	if got := testutil.ToFloat64(metrics.Leader); got != 1 {
+		t.Errorf("leader = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.LeaderTransitions); got != 2 {
+		t.Errorf("leader transitions = %v, want 2", got)
+	}
+}
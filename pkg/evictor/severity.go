@@ -0,0 +1,50 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"time"
+)
+
+// Severity is how severely a node is unhealthy, the most severe candidates are evicted first within the budget.
+type Severity struct {
+	// BadLinks is the number of bad links blamed on the node.
+	BadLinks int `json:"bad_links"`
+	// Magnitude is the mean latency of the bad links in PendingForEvict divided by Threshold, each latency is
+	// capped at twice of Threshold and failed probes count as twice of Threshold, so it is at most 2.
+	Magnitude float64 `json:"magnitude"`
+	// BadFor is the longest duration for which a bad link keeps exceeding Threshold, within the queried time range.
+	BadFor time.Duration `json:"bad_for"`
+	// Score is BadLinks + Magnitude + BadFor / PendingForEvict, it breaks ties between candidates with the same
+	// BadLinks, which are ranked first.
+	Score float64 `json:"score"`
+}
+
+// severities returns the Severity of the nodes with bad links blamed on them.
+func (it *Evictor) severities(metrics map[promhelper.Link]promhelper.TimeSeries) map[string]Severity {
+	result := make(map[string]Severity)
+	for node, suspicion := range attributeBadLinks(it.classifyLinks(metrics)) {
+		if len(suspicion.Blamed) == 0 {
+			continue
+		}
+		severity := Severity{BadLinks: len(suspicion.Blamed)}
+		var magnitude float64
+		for _, link := range suspicion.Blamed {
+			ts := metrics[link]
+			if it.config.Threshold > 0 {
+				if mean, ok := ts.CappedMeanLatencyFor(it.config.PendingForEvict, 2*it.config.Threshold); ok {
+					magnitude += float64(mean) / float64(it.config.Threshold)
+				}
+			}
+			if badFor := ts.ExceedingFor(it.config.Threshold); badFor > severity.BadFor {
+				severity.BadFor = badFor
+			}
+		}
+		severity.Magnitude = magnitude / float64(len(suspicion.Blamed))
+		severity.Score = float64(severity.BadLinks) + severity.Magnitude
+		if it.config.PendingForEvict > 0 {
+			severity.Score += float64(severity.BadFor) / float64(it.config.PendingForEvict)
+		}
+		result[node] = severity
+	}
+	return result
+}
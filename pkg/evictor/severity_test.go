@@ -0,0 +1,92 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvictor_severities(t *testing.T) {
+	now := time.Now()
+	// one sample every 15s for 2 minutes, the latest count samples are slow
+	series := func(slow time.Duration, count int) promhelper.TimeSeries {
+		var result promhelper.TimeSeries
+		for i := 0; i <= 8; i++ {
+			sample := promhelper.Sample{Timestamp: now.Add(time.Duration(i-8) * 15 * time.Second), Latency: time.Millisecond}
+			if i > 8-count {
+				sample.Latency = slow
+			}
+			result = append(result, sample)
+		}
+		return result
+	}
+	evictor := newTestEvictor(Config{Threshold: time.Second, PendingForEvict: time.Minute, PendingForRecover: time.Minute}, newFakeExecutor())
+	got := evictor.severities(map[promhelper.Link]promhelper.TimeSeries{
+		{From: "10.0.0.1", To: "10.0.0.2"}: series(1500*time.Millisecond, 9),
+		{From: "10.0.0.1", To: "10.0.0.3"}: series(time.Millisecond, 0),
+		{From: "10.0.0.2", To: "10.0.0.1"}: series(time.Millisecond, 0),
+		{From: "10.0.0.2", To: "10.0.0.3"}: series(time.Millisecond, 0),
+		{From: "10.0.0.3", To: "10.0.0.1"}: series(time.Millisecond, 0),
+		{From: "10.0.0.3", To: "10.0.0.2"}: series(5*time.Second, 5),
+	})
+	// both bad links are blamed on 10.0.0.2, the magnitude is the mean of 1.5 and 2 (capped from 5s)
+	want := map[string]Severity{
+		"10.0.0.2": {BadLinks: 2, Magnitude: 1.75, BadFor: 2 * time.Minute, Score: 5.75},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("severities() = %v, want %v", got, want)
+	}
+}
+
+func TestEvictor_findOutShouldEvictBySeverity(t *testing.T) {
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "10.0.0.1:20160"},
+		{Id: 2, Address: "10.0.0.2:20160"},
+		{Id: 3, Address: "10.0.0.3:20160"},
+		{Id: 4, Address: "10.0.0.4:20160"},
+		{Id: 5, Address: "10.0.0.5:20160"},
+	}
+	healthMap := map[string]NodeHealth{"10.0.0.1": Unhealthy, "10.0.0.2": Unhealthy, "10.0.0.3": Unhealthy, "10.0.0.4": Healthy,
+		"10.0.0.5": Unhealthy}
+	severities := map[string]Severity{
+		"10.0.0.1": {BadLinks: 1, Score: 2.5},
+		"10.0.0.2": {BadLinks: 3, Score: 4.5},
+		"10.0.0.3": {BadLinks: 1, Score: 3},
+		// fewer bad links with a higher score from magnitude and duration
+		"10.0.0.5": {BadLinks: 2, Score: 6},
+	}
+	tests := []struct {
+		name       string
+		maxEvicted uint
+		evicted    []uint
+		want       []uint
+	}{
+		{"budget for one", 1, nil, []uint{2}},
+		{"budget for two", 2, nil, []uint{2, 5}},
+		{"budget for all", 5, nil, []uint{2, 5, 3, 1}},
+		{"most severe evicted already", 2, []uint{2}, []uint{5}},
+		{"no budget", 1, []uint{4}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pd := newFakeExecutor(stores...)
+			for _, id := range tt.evicted {
+				_ = pd.AddEvictScheduler(id)
+			}
+			evictor := newTestEvictor(Config{MaxEvicted: tt.maxEvicted}, pd)
+			shouldEvict, err := evictor.findOutShouldEvict(healthMap, severities)
+			if err != nil {
+				t.Fatalf("findOutShouldEvict() error = %v", err)
+			}
+			var got []uint
+			for _, candidate := range shouldEvict {
+				got = append(got, candidate.Id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findOutShouldEvict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
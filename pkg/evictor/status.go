@@ -0,0 +1,111 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+// Status is a snapshot of evictor, which is updated after each loop.
+type Status struct {
+	UpdatedAt time.Time             `json:"updated_at"`
+	PdVersion string                `json:"pd_version"`
+	Nodes     map[string]NodeHealth `json:"nodes"`
+	Links     []LinkStatus          `json:"links"`
+	// Suspicions are how much each node is suspected to cause the bad links around it.
+	Suspicions map[string]Suspicion `json:"suspicions"`
+	Evicted    []EvictedStatus      `json:"evicted"`
+	// Degraded is not nil in the degraded mode.
+	Degraded *DegradedStatus `json:"degraded,omitempty"`
+	Config   Config          `json:"config"`
+}
+
+// LinkStatus is the latency summary of a link from the last fetched metrics.
+type LinkStatus struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	State LinkState `json:"state"`
+	promhelper.LatencySummary
+}
+
+// EvictedStatus is a mitigated store; Owned is false for the stores evicted by others.
+type EvictedStatus struct {
+	pdhelper.Store
+	Action    string     `json:"action"`
+	Owned     bool       `json:"owned"`
+	Reason    string     `json:"reason,omitempty"`
+	EvictedAt *time.Time `json:"evicted_at,omitempty"`
+	Evictions uint       `json:"evictions"`
+	// Flaps is the current flaps of the store, it should keep healthy for RecoverHold before recovered.
+	Flaps       uint          `json:"flaps"`
+	RecoverHold time.Duration `json:"recover_hold"`
+	// Note tells what the action actually does on the store, e.g. evict-slow-store only marks it.
+	Note string `json:"note,omitempty"`
+}
+
+// Status returns the snapshot of the last loop.
+func (it *Evictor) Status() Status {
+	it.statusLock.RLock()
+	defer it.statusLock.RUnlock()
+	return it.status
+}
+
+func (it *Evictor) updateStatus(healthMap map[string]NodeHealth, metrics map[promhelper.Link]promhelper.TimeSeries) {
+	status := Status{
+		UpdatedAt: time.Now(),
+		PdVersion: it.pdVersion,
+		Nodes:     healthMap,
+		Links:     []LinkStatus{},
+		Evicted:   []EvictedStatus{},
+		Config:    it.config,
+	}
+	if it.degraded != nil {
+		degraded := *it.degraded
+		status.Degraded = &degraded
+	}
+	states := it.classifyLinks(metrics)
+	for link, ts := range metrics {
+		status.Links = append(status.Links, LinkStatus{
+			From:           link.From,
+			To:             link.To,
+			State:          states[link],
+			LatencySummary: ts.Summary(),
+		})
+	}
+	status.Suspicions = attributeBadLinks(states)
+	sort.Slice(status.Links, func(i, j int) bool {
+		if status.Links[i].From != status.Links[j].From {
+			return status.Links[i].From < status.Links[j].From
+		}
+		return status.Links[i].To < status.Links[j].To
+	})
+
+	evicted, err := it.getEvicted()
+	if err != nil {
+		log.L().With(zap.Error(err)).Warn("failed to list evicted stores for status")
+	}
+	for _, store := range evicted {
+		item := EvictedStatus{Store: store.Store, Action: store.Action, Owned: it.state.Owns(store.Action, store.Id)}
+		if store.Action == ActionEvictSlowStore {
+			item.Note = slowStoreNote
+		}
+		if known, ok := it.state.Stores[store.Id]; ok && item.Owned {
+			evictedAt := known.EvictedAt
+			item.Reason = known.Reason
+			item.EvictedAt = &evictedAt
+			item.Evictions = known.Evictions
+			item.Flaps = known.FlapsAt(status.UpdatedAt, it.config.FlapDecay)
+			item.RecoverHold = it.recoverHold(store.Id, status.UpdatedAt)
+		}
+		status.Evicted = append(status.Evicted, item)
+	}
+	sort.Slice(status.Evicted, func(i, j int) bool { return status.Evicted[i].Id < status.Evicted[j].Id })
+
+	it.metrics.observeStatus(status)
+	it.statusLock.Lock()
+	defer it.statusLock.Unlock()
+	it.status = status
+}
@@ -0,0 +1,60 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"auto-failover-tikv-leader-evict/pkg/promhelper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvictor_updateStatus(t *testing.T) {
+	pd := newFakeExecutor(
+		pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"},
+		pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"},
+	)
+	evictor := newTestEvictor(Config{
+		MaxEvicted:        2,
+		Threshold:         time.Second,
+		PendingForEvict:   time.Minute,
+		PendingForRecover: time.Minute,
+	}, pd)
+	now := time.Now()
+	// store 1 is evicted by evictor, store 2 is evicted by others
+	_ = pd.AddEvictScheduler(1)
+	_ = pd.AddEvictScheduler(2)
+	evictor.state.RecordEvicted(pd.stores[0], ActionEvictLeader, "node 10.0.0.1 is unhealthy", now)
+
+	slow := promhelper.TimeSeries{
+		{Timestamp: now.Add(-2 * time.Minute), Latency: 2 * time.Second},
+		{Timestamp: now, Latency: 4 * time.Second},
+	}
+	fast := promhelper.TimeSeries{
+		{Timestamp: now.Add(-2 * time.Minute), Latency: time.Millisecond},
+		{Timestamp: now, Latency: time.Millisecond},
+	}
+	healthMap := map[string]NodeHealth{"10.0.0.1": Unhealthy, "10.0.0.2": Healthy}
+	evictor.updateStatus(healthMap, map[promhelper.Link]promhelper.TimeSeries{
+		{From: "10.0.0.2", To: "10.0.0.1"}: fast,
+		{From: "10.0.0.1", To: "10.0.0.2"}: slow,
+	})
+
+	got := evictor.Status()
+	if !reflect.DeepEqual(got.Nodes, healthMap) || !reflect.DeepEqual(got.Config, evictor.config) {
+		t.Errorf("Status() = %+v", got)
+	}
+	wantLinks := []LinkStatus{
+		{From: "10.0.0.1", To: "10.0.0.2", State: LinkBad, LatencySummary: slow.Summary()},
+		{From: "10.0.0.2", To: "10.0.0.1", State: LinkGood, LatencySummary: fast.Summary()},
+	}
+	if !reflect.DeepEqual(got.Links, wantLinks) {
+		t.Errorf("Status().Links = %+v, want %+v", got.Links, wantLinks)
+	}
+	wantEvicted := []EvictedStatus{
+		{Store: pd.stores[0], Action: ActionEvictLeader, Owned: true, Reason: "node 10.0.0.1 is unhealthy", EvictedAt: &now, Evictions: 1},
+		{Store: pd.stores[1], Action: ActionEvictLeader},
+	}
+	if !reflect.DeepEqual(got.Evicted, wantEvicted) {
+		t.Errorf("Status().Evicted = %+v, want %+v", got.Evicted, wantEvicted)
+	}
+}
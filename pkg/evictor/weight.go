@@ -0,0 +1,87 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+// adjustLeaderWeights lowers the leader weight of stores on Unstable nodes, so balance-leader-scheduler moves some
+// leaders away from them, and restores the original weights once the nodes become Healthy.
+// Unhealthy nodes keep the lowered weight, since their leaders are evicted anyway.
+func (it *Evictor) adjustLeaderWeights(ctx context.Context, healthMap map[string]NodeHealth) error {
+	originalWeights := it.state.LoweredWeights()
+	if it.config.UnstableLeaderWeight <= 0 && len(originalWeights) == 0 {
+		return nil
+	}
+	allStores, err := it.pd.ListStores()
+	if err != nil {
+		return err
+	}
+
+	topology := it.mapper.Map(nodesOf(healthMap), allStores)
+
+	if it.config.UnstableLeaderWeight > 0 {
+		for node, health := range healthMap {
+			if health != Unstable {
+				continue
+			}
+			for _, store := range topology.StoresOf(node) {
+				if _, lowered := originalWeights[store.Id]; lowered {
+					continue
+				}
+				weight, err := it.pd.GetStoreWeight(store.Id)
+				if err != nil {
+					log.L().With(zap.Error(err)).With(zap.Any("store", store)).Error("failed to get store weight")
+					continue
+				}
+				if weight.Leader <= it.config.UnstableLeaderWeight {
+					continue
+				}
+				lowered := weight
+				lowered.Leader = it.config.UnstableLeaderWeight
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := it.pd.SetStoreWeight(store.Id, lowered); err != nil {
+					log.L().With(zap.Error(err)).With(zap.Any("store", store)).Error("failed to lower leader weight")
+					continue
+				}
+				it.state.RecordWeightLowered(store, weight, time.Now())
+				it.saveState()
+				log.L().With(zap.Any("store", store)).With(zap.Any("original", weight)).With(zap.Any("lowered", lowered)).Info("tikv node leader weight lowered")
+			}
+		}
+	}
+
+	for storeId, original := range originalWeights {
+		var found bool
+		for _, store := range allStores {
+			if store.Id != storeId {
+				continue
+			}
+			found = true
+			if node, ok := topology.NodeOf(store); !ok || healthMap[node] != Healthy {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := it.pd.SetStoreWeight(store.Id, original); err != nil {
+				log.L().With(zap.Error(err)).With(zap.Any("store", store)).Error("failed to restore leader weight")
+				break
+			}
+			it.state.RecordWeightRestored(store, time.Now())
+			it.saveState()
+			log.L().With(zap.Any("store", store)).With(zap.Any("weight", original)).Info("tikv node leader weight restored")
+		}
+		if !found {
+			log.L().With(zap.Uint("store", storeId)).Warn("store with lowered leader weight does not exist any more")
+			it.state.RecordWeightRestored(pdhelper.Store{Id: storeId}, time.Now())
+			it.saveState()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package evictor
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEvictor_adjustLeaderWeights(t *testing.T) {
+	pd := newFakeExecutor(
+		pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"},
+		pdhelper.Store{Id: 2, Address: "10.0.0.2:20160"},
+		pdhelper.Store{Id: 3, Address: "10.0.0.3:20160"},
+	)
+	pd.weights[3] = pdhelper.StoreWeight{Leader: 2, Region: 3}
+	evictor := newTestEvictor(Config{UnstableLeaderWeight: 0.5}, pd)
+
+	steps := []struct {
+		name      string
+		healthMap map[string]NodeHealth
+		want      map[uint]pdhelper.StoreWeight
+	}{
+		{
+			name:      "unstable nodes get lowered",
+			healthMap: map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Unstable, "10.0.0.3": Unstable},
+			want: map[uint]pdhelper.StoreWeight{
+				2: {Leader: 0.5, Region: 1},
+				3: {Leader: 0.5, Region: 3},
+			},
+		},
+		{
+			name:      "unhealthy node keeps lowered weight",
+			healthMap: map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Unhealthy, "10.0.0.3": Unstable},
+			want: map[uint]pdhelper.StoreWeight{
+				2: {Leader: 0.5, Region: 1},
+				3: {Leader: 0.5, Region: 3},
+			},
+		},
+		{
+			name:      "healthy node gets original weight back",
+			healthMap: map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Unhealthy, "10.0.0.3": Healthy},
+			want: map[uint]pdhelper.StoreWeight{
+				2: {Leader: 0.5, Region: 1},
+				3: {Leader: 2, Region: 3},
+			},
+		},
+		{
+			name:      "all healthy",
+			healthMap: map[string]NodeHealth{"10.0.0.1": Healthy, "10.0.0.2": Healthy, "10.0.0.3": Healthy},
+			want: map[uint]pdhelper.StoreWeight{
+				2: {Leader: 1, Region: 1},
+				3: {Leader: 2, Region: 3},
+			},
+		},
+	}
+	for _, step := range steps {
+		if err := evictor.adjustLeaderWeights(context.Background(), step.healthMap); err != nil {
+			t.Fatalf("%s: adjustLeaderWeights() error = %v", step.name, err)
+		}
+		if !reflect.DeepEqual(pd.weights, step.want) {
+			t.Errorf("%s: weights = %v, want %v", step.name, pd.weights, step.want)
+		}
+	}
+	if lowered := evictor.state.LoweredWeights(); len(lowered) != 0 {
+		t.Errorf("LoweredWeights() = %v, want empty", lowered)
+	}
+}
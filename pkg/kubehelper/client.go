@@ -0,0 +1,139 @@
+package kubehelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultHTTPTimeout = 5 * time.Second
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Pod is the subset of a kubernetes pod which is needed for mapping.
+type Pod struct {
+	Name      string
+	Namespace string
+	NodeName  string
+	PodIP     string
+	HostIP    string
+}
+
+// Client lists pods from kubernetes; the fake one in tests works like the fake clientset of client-go.
+type Client interface {
+	ListPods(ctx context.Context, namespace, labelSelector string) ([]Pod, error)
+}
+
+// RESTClient talks to the kubernetes api server with json over http, so evictor does not depend on client-go.
+type RESTClient struct {
+	Server string
+	token  string
+	client *http.Client
+}
+
+// NewRESTClient creates a client for server without authentication, e.g. the address of `kubectl proxy`.
+func NewRESTClient(server string) *RESTClient {
+	return &RESTClient{
+		Server: strings.TrimRight(server, "/"),
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// NewInClusterClient creates a client with the service account mounted in the pod.
+func NewInClusterClient() (*RESTClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in kubernetes, KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT is missing")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("failed to parse ca of service account")
+	}
+	return &RESTClient{
+		Server: "https://" + net.JoinHostPort(host, port),
+		token:  strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout:   defaultHTTPTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			NodeName string `json:"nodeName"`
+		} `json:"spec"`
+		Status struct {
+			PodIP  string `json:"podIP"`
+			HostIP string `json:"hostIP"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (it *RESTClient) ListPods(ctx context.Context, namespace, labelSelector string) ([]Pod, error) {
+	path := fmt.Sprintf("%s/api/v1/namespaces/%s/pods", it.Server, url.PathEscape(namespace))
+	if labelSelector != "" {
+		path += "?labelSelector=" + url.QueryEscape(labelSelector)
+	}
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if it.token != "" {
+		req.Header.Set("Authorization", "Bearer "+it.token)
+	}
+	resp, err := it.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	log.L().With(zap.String("url", path)).With(zap.Int("status", resp.StatusCode)).Debug("kubernetes api server")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes responded %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(content)))
+	}
+	var list podList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	var result []Pod
+	for _, item := range list.Items {
+		result = append(result, Pod{
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			NodeName:  item.Spec.NodeName,
+			PodIP:     item.Status.PodIP,
+			HostIP:    item.Status.HostIP,
+		})
+	}
+	return result, nil
+}
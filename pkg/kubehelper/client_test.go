@@ -0,0 +1,38 @@
+package kubehelper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRESTClient_ListPods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/tidb-cluster/pods" || r.URL.Query().Get("labelSelector") != DefaultSelector {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"kind": "PodList", "items": [{
+			"metadata": {"name": "basic-tikv-0", "namespace": "tidb-cluster", "labels": {"app.kubernetes.io/component": "tikv"}},
+			"spec": {"nodeName": "node-1"},
+			"status": {"phase": "Running", "hostIP": "10.0.0.1", "podIP": "10.244.0.10"}
+		}]}`))
+	}))
+	defer server.Close()
+
+	client := NewRESTClient(server.URL)
+	got, err := client.ListPods(context.Background(), "tidb-cluster", DefaultSelector)
+	if err != nil {
+		t.Fatalf("ListPods() error = %v", err)
+	}
+	want := []Pod{{Name: "basic-tikv-0", Namespace: "tidb-cluster", NodeName: "node-1", PodIP: "10.244.0.10", HostIP: "10.0.0.1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPods() = %v, want %v", got, want)
+	}
+
+	if _, err := client.ListPods(context.Background(), "other", DefaultSelector); err == nil {
+		t.Errorf("ListPods() should fail on 404")
+	}
+}
@@ -0,0 +1,92 @@
+package kubehelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"context"
+	"go.uber.org/zap"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSelector selects the tikv pods created by tidb-operator.
+const DefaultSelector = "app.kubernetes.io/component=tikv"
+
+const defaultRefreshInterval = 30 * time.Second
+
+// Resolver resolves the DNS names of tikv pods, like "basic-tikv-2.basic-tikv-peer.tidb-cluster.svc",
+// to the pod IP and the IP of the node hosting it, so they could be joined with probes on either.
+// Other hosts are resolved by fallback.
+type Resolver struct {
+	client     Client
+	namespace  string
+	selector   string
+	fallback   addrhelper.Resolver
+	logMapping bool
+
+	sync.Mutex
+	pods        map[string]Pod
+	refreshedAt time.Time
+}
+
+func NewResolver(client Client, namespace, selector string, fallback addrhelper.Resolver, logMapping bool) *Resolver {
+	return &Resolver{
+		client:     client,
+		namespace:  namespace,
+		selector:   selector,
+		fallback:   fallback,
+		logMapping: logMapping,
+	}
+}
+
+func (it *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	pods, err := it.listPods(ctx)
+	if err != nil {
+		log.L().With(zap.Error(err)).Warn("failed to list tikv pods; resolve by fallback")
+	}
+	if pod, ok := pods[podNameOf(host, it.namespace)]; ok {
+		var result []string
+		for _, ip := range []string{pod.PodIP, pod.HostIP} {
+			if ip != "" {
+				result = append(result, ip)
+			}
+		}
+		return result, nil
+	}
+	return it.fallback.LookupHost(ctx, host)
+}
+
+// listPods lists the pods at most once per refresh interval; the last listed pods are returned on failures.
+func (it *Resolver) listPods(ctx context.Context) (map[string]Pod, error) {
+	it.Lock()
+	defer it.Unlock()
+	if it.pods != nil && time.Since(it.refreshedAt) < defaultRefreshInterval {
+		return it.pods, nil
+	}
+	listed, err := it.client.ListPods(ctx, it.namespace, it.selector)
+	if err != nil {
+		return it.pods, err
+	}
+	pods := make(map[string]Pod)
+	for _, pod := range listed {
+		pods[pod.Name] = pod
+	}
+	if it.logMapping && !reflect.DeepEqual(pods, it.pods) {
+		log.L().With(zap.Any("pods", pods)).Info("tikv pods mapping changed")
+	}
+	it.pods = pods
+	it.refreshedAt = time.Now()
+	return pods, nil
+}
+
+// podNameOf returns the pod name of host like "<pod>", "<pod>.<service>" or "<pod>.<service>.<namespace>.svc[.<cluster-domain>]";
+// it is empty if host is in other namespaces.
+func podNameOf(host, namespace string) string {
+	labels := strings.Split(addrhelper.NormalizeHost(host), ".")
+	if len(labels) >= 3 && labels[2] != namespace {
+		return ""
+	}
+	return labels[0]
+}
@@ -0,0 +1,104 @@
+package kubehelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeClient keeps pods in memory, like the fake clientset of client-go.
+type fakeClient struct {
+	pods  []Pod
+	err   error
+	calls int
+}
+
+func (it *fakeClient) ListPods(ctx context.Context, namespace, labelSelector string) ([]Pod, error) {
+	it.calls++
+	if it.err != nil {
+		return nil, it.err
+	}
+	var result []Pod
+	for _, pod := range it.pods {
+		if pod.Namespace == namespace {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{pods: []Pod{
+		{Name: "basic-tikv-0", Namespace: "tidb-cluster", NodeName: "node-1", PodIP: "10.244.0.10", HostIP: "10.0.0.1"},
+		{Name: "basic-tikv-1", Namespace: "tidb-cluster", NodeName: "node-2", PodIP: "10.244.1.10", HostIP: "10.0.0.2"},
+		{Name: "basic-tikv-2", Namespace: "other", NodeName: "node-3", PodIP: "10.244.2.10", HostIP: "10.0.0.3"},
+	}}
+}
+
+func TestResolver_LookupHost(t *testing.T) {
+	fallback := addrhelper.StaticResolver{"tikv-host": {"10.0.1.1"}}
+	tests := []struct {
+		name    string
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{"pod dns name", "basic-tikv-0.basic-tikv-peer.tidb-cluster.svc", []string{"10.244.0.10", "10.0.0.1"}, false},
+		{"pod dns name with cluster domain", "basic-tikv-1.basic-tikv-peer.tidb-cluster.svc.cluster.local.", []string{"10.244.1.10", "10.0.0.2"}, false},
+		{"pod name with service", "basic-tikv-1.basic-tikv-peer", []string{"10.244.1.10", "10.0.0.2"}, false},
+		{"pod in other namespace", "basic-tikv-2.basic-tikv-peer.other.svc", nil, true},
+		{"pod name in other namespace", "basic-tikv-0.basic-tikv-peer.other.svc", nil, true},
+		{"host out of kubernetes", "tikv-host", []string{"10.0.1.1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewResolver(newFakeClient(), "tidb-cluster", DefaultSelector, fallback, true)
+			got, err := resolver.LookupHost(context.Background(), tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_ListPodsOnce(t *testing.T) {
+	client := newFakeClient()
+	resolver := NewResolver(client, "tidb-cluster", DefaultSelector, addrhelper.StaticResolver{}, false)
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.LookupHost(context.Background(), "basic-tikv-0"); err != nil {
+			t.Fatalf("LookupHost() error = %v", err)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("ListPods() is called %d times, want 1", client.calls)
+	}
+
+	// the last listed pods are still used once kubernetes api is unavailable
+	client.err = fmt.Errorf("unavailable")
+	resolver.refreshedAt = resolver.refreshedAt.Add(-2 * defaultRefreshInterval)
+	if got, err := resolver.LookupHost(context.Background(), "basic-tikv-0"); err != nil || len(got) != 2 {
+		t.Errorf("LookupHost() = %v, %v, want the last listed pod", got, err)
+	}
+}
+
+func TestResolver_JoinProbesWithStores(t *testing.T) {
+	resolver := NewResolver(newFakeClient(), "tidb-cluster", DefaultSelector, addrhelper.StaticResolver{}, false)
+	stores := []pdhelper.Store{
+		{Id: 1, Address: "basic-tikv-0.basic-tikv-peer.tidb-cluster.svc:20160"},
+		{Id: 4, Address: "basic-tikv-1.basic-tikv-peer.tidb-cluster.svc:20160"},
+	}
+	// blackbox_exporter probes node IPs
+	topology := addrhelper.NewMapper(resolver, nil).Map([]string{"10.0.0.1", "10.0.0.2"}, stores)
+	want := map[uint]string{1: "10.0.0.1", 4: "10.0.0.2"}
+	for _, store := range stores {
+		if node, ok := topology.NodeOf(store); !ok || node != want[store.Id] {
+			t.Errorf("NodeOf(%d) = %v, %v, want %v", store.Id, node, ok, want[store.Id])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package pdhelper
+
+import "fmt"
+
+// Capabilities describes how evict-leader-scheduler behaves on a major version of PD.
+type Capabilities struct {
+	Major int
+	// SingleEvictLeaderScheduler means all evicted stores are kept in the "store-id-ranges" of one
+	// "evict-leader-scheduler", instead of one "evict-leader-scheduler-<id>" per store.
+	SingleEvictLeaderScheduler bool
+	// ConfigAPI means the stores of evict-leader-scheduler should be edited by
+	// "/pd/api/v1/scheduler-config/evict-leader-scheduler/{config,delete/<id>}" directly,
+	// and the scheduler is removed by PD itself once the last store is deleted.
+	ConfigAPI bool
+	// EvictSlowStoreScheduler means "evict-slow-store-scheduler" is available, which has been GA since v6.
+	EvictSlowStoreScheduler bool
+}
+
+// supportedMajors is the capability matrix of PD versions which could be operated by evictor.
+var supportedMajors = map[int]Capabilities{
+	3: {Major: 3},
+	4: {Major: 4, SingleEvictLeaderScheduler: true},
+	5: {Major: 5, SingleEvictLeaderScheduler: true, ConfigAPI: true},
+	6: {Major: 6, SingleEvictLeaderScheduler: true, ConfigAPI: true, EvictSlowStoreScheduler: true},
+	7: {Major: 7, SingleEvictLeaderScheduler: true, ConfigAPI: true, EvictSlowStoreScheduler: true},
+}
+
+// CapabilitiesFor returns the capabilities of the given PD version, like "v5.4.3".
+func CapabilitiesFor(version string) (Capabilities, error) {
+	major, err := ParseMajorVersion(version)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	capabilities, ok := supportedMajors[major]
+	if !ok {
+		return Capabilities{}, fmt.Errorf("unsupported pd version %s", version)
+	}
+	return capabilities, nil
+}
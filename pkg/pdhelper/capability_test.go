@@ -0,0 +1,152 @@
+package pdhelper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newRecordedPd serves the recorded responses of a pd version under testdata/<version>,
+// and records "<method> <path>" of requests which modify schedulers.
+func newRecordedPd(t *testing.T, version string, modifications *[]string) *httptest.Server {
+	routes := map[string]string{
+		"/version":    "version.json",
+		"/stores":     "stores.json",
+		"/schedulers": "schedulers.json",
+		"/scheduler-config/evict-leader-scheduler/list": "evict-leader-scheduler-list.json",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+		if r.Method != http.MethodGet {
+			*modifications = append(*modifications, r.Method+" "+path)
+			_, _ = w.Write([]byte(`"Success!"`))
+			return
+		}
+		file, ok := routes[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		content, err := ioutil.ReadFile(filepath.Join("testdata", version, file))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Capabilities
+		wantErr bool
+	}{
+		{version: "v2.1.19", wantErr: true},
+		{version: "v3.0.20", want: Capabilities{Major: 3}},
+		{version: "v4.0.8", want: Capabilities{Major: 4, SingleEvictLeaderScheduler: true}},
+		{version: "v5.4.3", want: Capabilities{Major: 5, SingleEvictLeaderScheduler: true, ConfigAPI: true}},
+		{version: "v7.1.1", want: Capabilities{Major: 7, SingleEvictLeaderScheduler: true, ConfigAPI: true, EvictSlowStoreScheduler: true}},
+		{version: "v8.1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := CapabilitiesFor(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CapabilitiesFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CapabilitiesFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutorHTTP_RecordedResponses(t *testing.T) {
+	tests := []struct {
+		version string
+		// wantModifications are requests sent by AddEvictScheduler(5) then RemoveEvictScheduler(4)
+		wantModifications []string
+	}{
+		{
+			version: "v3",
+			wantModifications: []string{
+				"POST /schedulers",
+				"DELETE /schedulers/evict-leader-scheduler-4",
+			},
+		},
+		{
+			version: "v4",
+			wantModifications: []string{
+				"POST /schedulers",
+				"DELETE /schedulers/evict-leader-scheduler-4",
+			},
+		},
+		{
+			version: "v5",
+			wantModifications: []string{
+				"POST /scheduler-config/evict-leader-scheduler/config",
+				"DELETE /scheduler-config/evict-leader-scheduler/delete/4",
+			},
+		},
+		{
+			version: "v6",
+			wantModifications: []string{
+				"POST /scheduler-config/evict-leader-scheduler/config",
+				"DELETE /scheduler-config/evict-leader-scheduler/delete/4",
+			},
+		},
+		{
+			version: "v7",
+			wantModifications: []string{
+				"POST /scheduler-config/evict-leader-scheduler/config",
+				"DELETE /scheduler-config/evict-leader-scheduler/delete/4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			var modifications []string
+			server := newRecordedPd(t, tt.version, &modifications)
+			defer server.Close()
+
+			detected, err := DetectVersion(server.URL)
+			if err != nil {
+				t.Fatalf("DetectVersion() error = %v", err)
+			}
+			capabilities, err := CapabilitiesFor(detected)
+			if err != nil {
+				t.Fatalf("CapabilitiesFor() error = %v", err)
+			}
+			executor := NewExecutorHTTP(server.URL, capabilities)
+
+			evicted, err := executor.ListEvictedStore()
+			if err != nil {
+				t.Fatalf("ListEvictedStore() error = %v", err)
+			}
+			want := []Store{{
+				Id:        4,
+				Address:   "10.0.1.2:20160",
+				Labels:    []StoreLabel{{Key: "zone", Value: "z2"}, {Key: "host", Value: "h2"}},
+				StateName: StoreStateUp,
+			}}
+			if !reflect.DeepEqual(evicted, want) {
+				t.Errorf("ListEvictedStore() = %v, want %v", evicted, want)
+			}
+
+			if err := executor.AddEvictScheduler(5); err != nil {
+				t.Fatalf("AddEvictScheduler() error = %v", err)
+			}
+			if err := executor.RemoveEvictScheduler(4); err != nil {
+				t.Fatalf("RemoveEvictScheduler() error = %v", err)
+			}
+			if !reflect.DeepEqual(modifications, tt.wantModifications) {
+				t.Errorf("modifications = %v, want %v", modifications, tt.wantModifications)
+			}
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package pdhelper
 
 const evictLeaderScheduler = "evict-leader-scheduler"
+const evictSlowStoreScheduler = "evict-slow-store-scheduler"
 
 type Executor interface {
 	AddEvictScheduler(storeId uint) error
 	RemoveEvictScheduler(storeId uint) error
 	ListStores() ([]Store, error)
 	ListEvictedStore() ([]Store, error)
+	// AddEvictSlowStoreScheduler lets PD evict leaders from stores it considers slow by itself.
+	AddEvictSlowStoreScheduler() error
+	RemoveEvictSlowStoreScheduler() error
+	HasEvictSlowStoreScheduler() (bool, error)
+	GetStoreWeight(storeId uint) (StoreWeight, error)
+	SetStoreWeight(storeId uint, weight StoreWeight) error
 }
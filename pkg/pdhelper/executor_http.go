@@ -0,0 +1,240 @@
+package pdhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const apiPrefix = "/pd/api/v1"
+const defaultHTTPTimeout = 10 * time.Second
+
+// ExecutorHTTP talks to the PD HTTP API directly, so it does not require pd-ctl on the host.
+// The endpoints it uses are picked by the Capabilities of the PD version.
+type ExecutorHTTP struct {
+	PdAddr       string
+	Capabilities Capabilities
+	client       *http.Client
+}
+
+func NewExecutorHTTP(pdAddr string, capabilities Capabilities) *ExecutorHTTP {
+	return &ExecutorHTTP{
+		PdAddr:       normalizePdURL(pdAddr),
+		Capabilities: capabilities,
+		client:       &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+type addSchedulerRequest struct {
+	Name    string `json:"name"`
+	StoreId uint   `json:"store_id,omitempty"`
+}
+
+type schedulerConfigRequest struct {
+	StoreId uint `json:"store_id"`
+}
+
+// StatusError is returned when PD responds with an unexpected status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (it *StatusError) Error() string {
+	return fmt.Sprintf("pd responded %d for %s %s: %s", it.StatusCode, it.Method, it.URL, strings.TrimSpace(it.Body))
+}
+
+func (it *ExecutorHTTP) AddEvictScheduler(storeId uint) error {
+	if it.Capabilities.ConfigAPI {
+		existed, err := it.hasScheduler(evictLeaderScheduler)
+		if err != nil {
+			return err
+		}
+		if existed {
+			path := fmt.Sprintf("/scheduler-config/%s/config", evictLeaderScheduler)
+			log.L().With(zap.String("url", it.PdAddr+apiPrefix+path)).With(zap.Uint("store", storeId)).Info("add a store to the evict scheduler")
+			return it.do(http.MethodPost, path, schedulerConfigRequest{StoreId: storeId}, nil)
+		}
+	}
+	log.L().With(zap.String("url", it.PdAddr+apiPrefix+"/schedulers")).With(zap.Uint("store", storeId)).Info("add an evict scheduler")
+	return it.do(http.MethodPost, "/schedulers", addSchedulerRequest{
+		Name:    evictLeaderScheduler,
+		StoreId: storeId,
+	}, nil)
+}
+
+func (it *ExecutorHTTP) RemoveEvictScheduler(storeId uint) error {
+	var path string
+	if it.Capabilities.ConfigAPI {
+		path = fmt.Sprintf("/scheduler-config/%s/delete/%d", evictLeaderScheduler, storeId)
+	} else {
+		// PD v4 redirects "evict-leader-scheduler-<id>" to the config deletion of the single evict-leader-scheduler.
+		path = fmt.Sprintf("/schedulers/%s-%d", evictLeaderScheduler, storeId)
+	}
+	log.L().With(zap.String("url", it.PdAddr+apiPrefix+path)).Info("remove an evict scheduler")
+	return it.do(http.MethodDelete, path, nil, nil)
+}
+
+func (it *ExecutorHTTP) AddEvictSlowStoreScheduler() error {
+	if !it.Capabilities.EvictSlowStoreScheduler {
+		return fmt.Errorf("%s is not supported by pd v%d", evictSlowStoreScheduler, it.Capabilities.Major)
+	}
+	log.L().With(zap.String("url", it.PdAddr+apiPrefix+"/schedulers")).Info("add an evict slow store scheduler")
+	return it.do(http.MethodPost, "/schedulers", addSchedulerRequest{Name: evictSlowStoreScheduler}, nil)
+}
+
+func (it *ExecutorHTTP) RemoveEvictSlowStoreScheduler() error {
+	path := "/schedulers/" + evictSlowStoreScheduler
+	log.L().With(zap.String("url", it.PdAddr+apiPrefix+path)).Info("remove an evict slow store scheduler")
+	return it.do(http.MethodDelete, path, nil, nil)
+}
+
+func (it *ExecutorHTTP) HasEvictSlowStoreScheduler() (bool, error) {
+	return it.hasScheduler(evictSlowStoreScheduler)
+}
+
+func (it *ExecutorHTTP) GetStoreWeight(storeId uint) (StoreWeight, error) {
+	var store StoreItem
+	if err := it.do(http.MethodGet, fmt.Sprintf("/store/%d", storeId), nil, &store); err != nil {
+		return StoreWeight{}, err
+	}
+	return StoreWeight{Leader: store.Status.LeaderWeight, Region: store.Status.RegionWeight}, nil
+}
+
+func (it *ExecutorHTTP) SetStoreWeight(storeId uint, weight StoreWeight) error {
+	path := fmt.Sprintf("/store/%d/weight", storeId)
+	log.L().With(zap.String("url", it.PdAddr+apiPrefix+path)).With(zap.Any("weight", weight)).Info("set store weight")
+	return it.do(http.MethodPost, path, weight, nil)
+}
+
+func (it *ExecutorHTTP) ListStores() ([]Store, error) {
+	pdOutput := PdStore{}
+	if err := it.do(http.MethodGet, "/stores", nil, &pdOutput); err != nil {
+		return nil, err
+	}
+	var result []Store
+	for _, store := range pdOutput.Stores {
+		result = append(result, store.Store)
+	}
+	return result, nil
+}
+
+func (it *ExecutorHTTP) ListEvictedStore() ([]Store, error) {
+	storeIds, err := it.listEvictedStoreIds()
+	if err != nil {
+		return nil, err
+	}
+	if len(storeIds) == 0 {
+		return nil, nil
+	}
+
+	stores, err := it.ListStores()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Store
+	for _, store := range stores {
+		for _, id := range storeIds {
+			if store.Id == id {
+				result = append(result, store)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (it *ExecutorHTTP) listEvictedStoreIds() ([]uint, error) {
+	if !it.Capabilities.SingleEvictLeaderScheduler {
+		var schedulers PdSchedulerShow
+		if err := it.do(http.MethodGet, "/schedulers", nil, &schedulers); err != nil {
+			return nil, err
+		}
+		return schedulers.FetchStoreIds(), nil
+	}
+
+	// all evicted stores are kept in the config of a single scheduler; pd responds 404 if it does not exist
+	var config PdSchedulerConfig
+	err := it.do(http.MethodGet, fmt.Sprintf("/scheduler-config/%s/list", evictLeaderScheduler), nil, &config)
+	if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config.FetchStoreIds(), nil
+}
+
+func (it *ExecutorHTTP) hasScheduler(name string) (bool, error) {
+	var schedulers []string
+	if err := it.do(http.MethodGet, "/schedulers", nil, &schedulers); err != nil {
+		return false, err
+	}
+	for _, item := range schedulers {
+		if item == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// do sends a request to PD; request is encoded as json body if not nil, and response is decoded into result if not nil.
+func (it *ExecutorHTTP) do(method, path string, request interface{}, result interface{}) error {
+	url := it.PdAddr + apiPrefix + path
+	var body bytes.Buffer
+	if request != nil {
+		if err := json.NewEncoder(&body).Encode(request); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := it.client.Do(req)
+	if err != nil {
+		log.L().With(zap.Error(err)).With(zap.String("method", method)).With(zap.String("url", url)).Error("failed to request pd")
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	log.L().With(zap.String("method", method)).With(zap.String("url", url)).With(zap.Int("status", resp.StatusCode)).With(zap.String("output", string(content))).Debug("pd http api")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Body:       string(content),
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(content, result); err != nil {
+		log.L().With(zap.Error(err)).With(zap.String("output", string(content))).Warn("failed to parse output of pd http api")
+		return err
+	}
+	return nil
+}
+
+func normalizePdURL(pdAddr string) string {
+	pdAddr = strings.TrimRight(pdAddr, "/")
+	if strings.HasPrefix(pdAddr, "http://") || strings.HasPrefix(pdAddr, "https://") {
+		return pdAddr
+	}
+	return "http://" + pdAddr
+}
@@ -0,0 +1,263 @@
+package pdhelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePd is a minimal stand-in of PD's http api for evict-leader-scheduler.
+type fakePd struct {
+	sync.Mutex
+	capabilities Capabilities
+	stores       []Store
+	evicted      map[uint]bool
+	weights      map[uint]StoreWeight
+	// requests records "<method> <path>" of each received request
+	requests []string
+}
+
+func newFakePd(capabilities Capabilities, stores ...Store) *fakePd {
+	return &fakePd{
+		capabilities: capabilities,
+		stores:       stores,
+		evicted:      make(map[uint]bool),
+		weights:      make(map[uint]StoreWeight),
+	}
+}
+
+func (it *fakePd) weightOf(storeId uint) StoreWeight {
+	if weight, ok := it.weights[storeId]; ok {
+		return weight
+	}
+	return StoreWeight{Leader: 1, Region: 1}
+}
+
+func (it *fakePd) removeEvicted(w http.ResponseWriter, path string) {
+	id, err := strconv.ParseUint(path[strings.LastIndexAny(path, "-/")+1:], 10, 32)
+	if err != nil || !it.evicted[uint(id)] {
+		http.Error(w, "scheduler not found", http.StatusInternalServerError)
+		return
+	}
+	delete(it.evicted, uint(id))
+	_ = json.NewEncoder(w).Encode("The scheduler is removed.")
+}
+
+func (it *fakePd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	it.Lock()
+	defer it.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	it.requests = append(it.requests, r.Method+" "+path)
+	singleScheduler := it.capabilities.SingleEvictLeaderScheduler
+	switch {
+	case path == "/stores" && r.Method == http.MethodGet:
+		var output PdStore
+		for _, store := range it.stores {
+			output.Stores = append(output.Stores, StoreItem{Store: store})
+		}
+		output.Count = len(output.Stores)
+		_ = json.NewEncoder(w).Encode(output)
+	case strings.HasPrefix(path, "/store/"):
+		segments := strings.Split(strings.TrimPrefix(path, "/store/"), "/")
+		id, err := strconv.ParseUint(segments[0], 10, 32)
+		if err != nil {
+			http.Error(w, "invalid store id", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case len(segments) == 1 && r.Method == http.MethodGet:
+			weight := it.weightOf(uint(id))
+			_ = json.NewEncoder(w).Encode(StoreItem{
+				Store:  Store{Id: uint(id)},
+				Status: StoreStatus{LeaderWeight: weight.Leader, RegionWeight: weight.Region},
+			})
+		case len(segments) == 2 && segments[1] == "weight" && r.Method == http.MethodPost:
+			var weight StoreWeight
+			if err := json.NewDecoder(r.Body).Decode(&weight); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			it.weights[uint(id)] = weight
+			_ = json.NewEncoder(w).Encode("The store's label is updated.")
+		default:
+			http.NotFound(w, r)
+		}
+	case path == "/schedulers" && r.Method == http.MethodGet:
+		names := []string{"balance-leader-scheduler"}
+		if singleScheduler {
+			if len(it.evicted) > 0 {
+				names = append(names, evictLeaderScheduler)
+			}
+		} else {
+			for id := range it.evicted {
+				names = append(names, fmt.Sprintf("%s-%d", evictLeaderScheduler, id))
+			}
+		}
+		_ = json.NewEncoder(w).Encode(names)
+	case path == "/schedulers" && r.Method == http.MethodPost:
+		var request addSchedulerRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Name != evictLeaderScheduler {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		it.evicted[request.StoreId] = true
+		_ = json.NewEncoder(w).Encode("The scheduler is created.")
+	case strings.HasPrefix(path, "/schedulers/"+evictLeaderScheduler+"-") && r.Method == http.MethodDelete:
+		it.removeEvicted(w, path)
+	case path == "/scheduler-config/"+evictLeaderScheduler+"/config" && r.Method == http.MethodPost && singleScheduler && len(it.evicted) > 0:
+		var request schedulerConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		it.evicted[request.StoreId] = true
+		_ = json.NewEncoder(w).Encode("The scheduler has been applied.")
+	case strings.HasPrefix(path, "/scheduler-config/"+evictLeaderScheduler+"/delete/") && r.Method == http.MethodDelete && singleScheduler:
+		it.removeEvicted(w, path)
+	case path == "/scheduler-config/"+evictLeaderScheduler+"/list" && singleScheduler && len(it.evicted) > 0:
+		config := PdSchedulerConfig{StoreIdRanges: make(map[uint][]interface{})}
+		for id := range it.evicted {
+			config.StoreIdRanges[id] = []interface{}{map[string]string{"start-key": "", "end-key": ""}}
+		}
+		_ = json.NewEncoder(w).Encode(config)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func sortedIds(stores []Store) []uint {
+	var result []uint
+	for _, store := range stores {
+		result = append(result, store.Id)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func TestExecutorHTTP_EvictAndRecover(t *testing.T) {
+	stores := []Store{
+		{Id: 1, Address: "10.0.0.1:20160"},
+		{Id: 4, Address: "10.0.0.2:20160"},
+		{Id: 5, Address: "10.0.0.3:20160"},
+	}
+	for _, version := range []string{"v3.0.20", "v4.0.8", "v5.4.3", "v6.5.0", "v7.1.1"} {
+		t.Run(version, func(t *testing.T) {
+			capabilities, err := CapabilitiesFor(version)
+			if err != nil {
+				t.Fatalf("CapabilitiesFor() error = %v", err)
+			}
+			pd := newFakePd(capabilities, stores...)
+			server := httptest.NewServer(pd)
+			defer server.Close()
+			executor := NewExecutorHTTP(server.URL, capabilities)
+
+			allStores, err := executor.ListStores()
+			if err != nil {
+				t.Fatalf("ListStores() error = %v", err)
+			}
+			if !reflect.DeepEqual(allStores, stores) {
+				t.Errorf("ListStores() = %v, want %v", allStores, stores)
+			}
+
+			evicted, err := executor.ListEvictedStore()
+			if err != nil || evicted != nil {
+				t.Fatalf("ListEvictedStore() = %v, %v, want nil, nil", evicted, err)
+			}
+
+			for _, id := range []uint{4, 5} {
+				if err := executor.AddEvictScheduler(id); err != nil {
+					t.Fatalf("AddEvictScheduler(%d) error = %v", id, err)
+				}
+			}
+			evicted, err = executor.ListEvictedStore()
+			if err != nil {
+				t.Fatalf("ListEvictedStore() error = %v", err)
+			}
+			if got := sortedIds(evicted); !reflect.DeepEqual(got, []uint{4, 5}) {
+				t.Errorf("ListEvictedStore() = %v, want %v", got, []uint{4, 5})
+			}
+
+			if err := executor.RemoveEvictScheduler(4); err != nil {
+				t.Fatalf("RemoveEvictScheduler() error = %v", err)
+			}
+			evicted, err = executor.ListEvictedStore()
+			if err != nil {
+				t.Fatalf("ListEvictedStore() error = %v", err)
+			}
+			if got := sortedIds(evicted); !reflect.DeepEqual(got, []uint{5}) {
+				t.Errorf("ListEvictedStore() = %v, want %v", got, []uint{5})
+			}
+
+			usedConfigAPI := false
+			for _, request := range pd.requests {
+				if strings.HasPrefix(request, "DELETE /scheduler-config/") {
+					usedConfigAPI = true
+				}
+			}
+			if usedConfigAPI != capabilities.ConfigAPI {
+				t.Errorf("requests %v used config api = %v, want %v", pd.requests, usedConfigAPI, capabilities.ConfigAPI)
+			}
+		})
+	}
+}
+
+func TestExecutorHTTP_StatusError(t *testing.T) {
+	server := httptest.NewServer(newFakePd(Capabilities{Major: 3}))
+	defer server.Close()
+	executor := NewExecutorHTTP(server.URL, Capabilities{Major: 3})
+
+	err := executor.RemoveEvictScheduler(42)
+	statusErr, ok := err.(*StatusError)
+	if !ok {
+		t.Fatalf("RemoveEvictScheduler() error = %v, want *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %d, want %d", statusErr.StatusCode, http.StatusInternalServerError)
+	}
+}
+
+func TestExecutorHTTP_StoreWeight(t *testing.T) {
+	server := httptest.NewServer(newFakePd(Capabilities{Major: 4, SingleEvictLeaderScheduler: true}))
+	defer server.Close()
+	executor := NewExecutorHTTP(server.URL, Capabilities{Major: 4, SingleEvictLeaderScheduler: true})
+
+	weight, err := executor.GetStoreWeight(1)
+	if err != nil {
+		t.Fatalf("GetStoreWeight() error = %v", err)
+	}
+	if want := (StoreWeight{Leader: 1, Region: 1}); weight != want {
+		t.Errorf("GetStoreWeight() = %v, want %v", weight, want)
+	}
+	want := StoreWeight{Leader: 0.25, Region: 1}
+	if err := executor.SetStoreWeight(1, want); err != nil {
+		t.Fatalf("SetStoreWeight() error = %v", err)
+	}
+	if weight, err = executor.GetStoreWeight(1); err != nil || weight != want {
+		t.Errorf("GetStoreWeight() = %v, %v, want %v", weight, err, want)
+	}
+}
+
+func Test_normalizePdURL(t *testing.T) {
+	tests := []struct {
+		pdAddr string
+		want   string
+	}{
+		{"10.0.0.1:2379", "http://10.0.0.1:2379"},
+		{"http://10.0.0.1:2379/", "http://10.0.0.1:2379"},
+		{"https://pd.example.com:2379", "https://pd.example.com:2379"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pdAddr, func(t *testing.T) {
+			if got := normalizePdURL(tt.pdAddr); got != tt.want {
+				t.Errorf("normalizePdURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
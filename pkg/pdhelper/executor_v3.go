@@ -50,6 +50,26 @@ func (it *ExecutorV3) RemoveEvictScheduler(storeId uint) error {
 	}
 }
 
+func (it *ExecutorV3) AddEvictSlowStoreScheduler() error {
+	return fmt.Errorf("%s is not supported by pd v3", evictSlowStoreScheduler)
+}
+
+func (it *ExecutorV3) RemoveEvictSlowStoreScheduler() error {
+	return fmt.Errorf("%s is not supported by pd v3", evictSlowStoreScheduler)
+}
+
+func (it *ExecutorV3) HasEvictSlowStoreScheduler() (bool, error) {
+	return false, nil
+}
+
+func (it *ExecutorV3) GetStoreWeight(storeId uint) (StoreWeight, error) {
+	return pdCtlGetStoreWeight(it.PdAddr, storeId)
+}
+
+func (it *ExecutorV3) SetStoreWeight(storeId uint, weight StoreWeight) error {
+	return pdCtlSetStoreWeight(it.PdAddr, storeId, weight)
+}
+
 func (it *ExecutorV3) ListStores() ([]Store, error) {
 	out, err := exec.Command("pd-ctl", "-u", it.PdAddr, "store").CombinedOutput()
 	if err != nil {
@@ -49,6 +49,28 @@ func (it *ExecutorV4) RemoveEvictScheduler(storeId uint) error {
 	}
 }
 
+func (it *ExecutorV4) AddEvictSlowStoreScheduler() error {
+	log.L().With(zap.String("command", fmt.Sprintf("pd-ctl -u %s scheduler add %s", it.PdAddr, evictSlowStoreScheduler))).Info("add an evict slow store scheduler")
+	return runPdCtlExpectSuccess(it.PdAddr, "scheduler", "add", evictSlowStoreScheduler)
+}
+
+func (it *ExecutorV4) RemoveEvictSlowStoreScheduler() error {
+	log.L().With(zap.String("command", fmt.Sprintf("pd-ctl -u %s scheduler remove %s", it.PdAddr, evictSlowStoreScheduler))).Info("remove an evict slow store scheduler")
+	return runPdCtlExpectSuccess(it.PdAddr, "scheduler", "remove", evictSlowStoreScheduler)
+}
+
+func (it *ExecutorV4) HasEvictSlowStoreScheduler() (bool, error) {
+	return pdCtlHasScheduler(it.PdAddr, evictSlowStoreScheduler)
+}
+
+func (it *ExecutorV4) GetStoreWeight(storeId uint) (StoreWeight, error) {
+	return pdCtlGetStoreWeight(it.PdAddr, storeId)
+}
+
+func (it *ExecutorV4) SetStoreWeight(storeId uint, weight StoreWeight) error {
+	return pdCtlSetStoreWeight(it.PdAddr, storeId, weight)
+}
+
 func (it *ExecutorV4) ListStores() ([]Store, error) {
 	out, err := exec.Command("pd-ctl", "-u", it.PdAddr, "store").CombinedOutput()
 	if err != nil {
@@ -0,0 +1,76 @@
+package pdhelper
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/log"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// runPdCtl executes pd-ctl against pdAddr and returns its combined output.
+func runPdCtl(pdAddr string, args ...string) (string, error) {
+	command := strings.Join(append([]string{"pd-ctl", "-u", pdAddr}, args...), " ")
+	out, err := exec.Command("pd-ctl", append([]string{"-u", pdAddr}, args...)...).CombinedOutput()
+	if err != nil {
+		log.L().With(zap.String("command", command)).With(zap.String("out", string(out))).Error("failed to execute pd-ctl")
+		return string(out), err
+	}
+	log.L().With(zap.String("command", command)).With(zap.String("output", string(out))).Debug("pd-ctl")
+	return string(out), nil
+}
+
+// runPdCtlExpectSuccess executes pd-ctl and treats the output without "Success" as failure.
+func runPdCtlExpectSuccess(pdAddr string, args ...string) error {
+	out, err := runPdCtl(pdAddr, args...)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(out, "Success") {
+		return fmt.Errorf("failed to execute pd-ctl %s, %s", strings.Join(args, " "), out)
+	}
+	return nil
+}
+
+// pdCtlHasScheduler checks whether the scheduler is listed by "pd-ctl scheduler show".
+func pdCtlHasScheduler(pdAddr string, name string) (bool, error) {
+	out, err := runPdCtl(pdAddr, "scheduler", "show")
+	if err != nil {
+		return false, err
+	}
+	var schedulers []string
+	if err := json.Unmarshal([]byte(out), &schedulers); err != nil {
+		log.L().With(zap.Error(err)).With(zap.String("output", out)).Error("failed to parse output for pd-ctl scheduler show")
+		return false, err
+	}
+	for _, item := range schedulers {
+		if item == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pdCtlGetStoreWeight reads the weights of a store by "pd-ctl store <id>".
+func pdCtlGetStoreWeight(pdAddr string, storeId uint) (StoreWeight, error) {
+	out, err := runPdCtl(pdAddr, "store", fmt.Sprintf("%d", storeId))
+	if err != nil {
+		return StoreWeight{}, err
+	}
+	var store StoreItem
+	if err := json.Unmarshal([]byte(out), &store); err != nil {
+		log.L().With(zap.Error(err)).With(zap.String("output", out)).Error("failed to parse output for pd-ctl store")
+		return StoreWeight{}, err
+	}
+	return StoreWeight{Leader: store.Status.LeaderWeight, Region: store.Status.RegionWeight}, nil
+}
+
+// pdCtlSetStoreWeight updates the weights of a store by "pd-ctl store weight <id> <leader> <region>".
+func pdCtlSetStoreWeight(pdAddr string, storeId uint, weight StoreWeight) error {
+	log.L().With(zap.Uint("store", storeId)).With(zap.Any("weight", weight)).Info("set store weight")
+	return runPdCtlExpectSuccess(pdAddr, "store", "weight", fmt.Sprintf("%d", storeId),
+		strconv.FormatFloat(weight.Leader, 'f', -1, 64),
+		strconv.FormatFloat(weight.Region, 'f', -1, 64))
+}
@@ -6,10 +6,43 @@ type PdStore struct {
 }
 
 type StoreItem struct {
-	Store Store `json:"store"`
+	Store  Store       `json:"store"`
+	Status StoreStatus `json:"status"`
+}
+
+type StoreStatus struct {
+	LeaderWeight float64 `json:"leader_weight"`
+	RegionWeight float64 `json:"region_weight"`
+}
+
+// StoreWeight is the leader weight and region weight of a store, which are used by balance schedulers.
+type StoreWeight struct {
+	Leader float64 `json:"leader"`
+	Region float64 `json:"region"`
 }
 
 type Store struct {
 	Id      uint   `json:"id"`
 	Address string `json:"address"`
+	// Labels are the location labels of the store, like zone, rack and host.
+	Labels    []StoreLabel `json:"labels,omitempty"`
+	StateName string       `json:"state_name,omitempty"`
+}
+
+type StoreLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// StoreStateUp is the state of stores which serve normally, others are offline, disconnected, down or tombstone.
+const StoreStateUp = "Up"
+
+// Label returns the value of the label with key, it is false if the store does not have that label.
+func (it Store) Label(key string) (string, bool) {
+	for _, label := range it.Labels {
+		if label.Key == key {
+			return label.Value, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,47 @@
+package pdhelper
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type pdVersion struct {
+	Version string `json:"version"`
+}
+
+// DetectVersion queries the version of PD, like "v4.0.8", through its http api.
+// It tries "/pd/api/v1/version" first, then falls back to "/pd/api/v1/status".
+func DetectVersion(pdAddr string) (string, error) {
+	executor := NewExecutorHTTP(pdAddr, Capabilities{})
+	var lastErr error
+	for _, path := range []string{"/version", "/status"} {
+		var output pdVersion
+		err := executor.do(http.MethodGet, path, nil, &output)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if output.Version == "" {
+			lastErr = fmt.Errorf("pd responded empty version for %s", path)
+			continue
+		}
+		return output.Version, nil
+	}
+	return "", lastErr
+}
+
+// ParseMajorVersion extracts the major version from versions like "v4.0.8", "3.0.20" or "v5.0.0-nightly".
+func ParseMajorVersion(version string) (int, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	major := trimmed
+	if index := strings.IndexAny(trimmed, ".-"); index >= 0 {
+		major = trimmed[:index]
+	}
+	parsed, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse pd version %s", version)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,80 @@
+package pdhelper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		wantErr bool
+	}{
+		{version: "v3.0.20", want: 3},
+		{version: "4.0.8", want: 4},
+		{version: "v5.0.0-nightly", want: 5},
+		{version: "v7", want: 7},
+		{version: "nightly", wantErr: true},
+		{version: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseMajorVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMajorVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMajorVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "version api",
+			routes: map[string]string{"/pd/api/v1/version": `{"version":"v4.0.8"}`},
+			want:   "v4.0.8",
+		},
+		{
+			name: "fallback to status api",
+			routes: map[string]string{
+				"/pd/api/v1/status": `{"build_ts":"2020-10-30 08:21:58","version":"v3.1.2","git_hash":"0d8a4a4"}`,
+			},
+			want: "v3.1.2",
+		},
+		{
+			name:    "no api available",
+			routes:  map[string]string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if body, ok := tt.routes[r.URL.Path]; ok {
+					_, _ = w.Write([]byte(body))
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			defer server.Close()
+
+			got, err := DetectVersion(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,25 +1,39 @@
 package promhelper
 
 import (
+	"auto-failover-tikv-leader-evict/pkg/addrhelper"
+	"auto-failover-tikv-leader-evict/pkg/log"
 	"context"
 	"fmt"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
-	"strings"
+	"go.uber.org/zap"
 	"time"
 )
 
-// It is a metric exposed by blackbox_exporter.
-const IcmpPingQuery = "probe_duration_seconds{ping!=\"\"}"
-const LabelInstance = "instance"
-const LabelPing = "ping"
+// validationRange is the range of the query in CheckQuery.
+const validationRange = 5 * time.Minute
+
+// InvalidQueryError is returned by CheckQuery if the query itself is invalid, rather than prometheus is unreachable.
+type InvalidQueryError struct {
+	Query  string
+	Reason string
+}
+
+func (it *InvalidQueryError) Error() string {
+	return fmt.Sprintf("invalid query %s: %s", it.Query, it.Reason)
+}
 
 type QueryClient struct {
-	prom v1.API
+	prom  v1.API
+	query QueryConfig
 }
 
-func NewQueryClient(promAddr string) (*QueryClient, error) {
+func NewQueryClient(promAddr string, query QueryConfig) (*QueryClient, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
 	promClient, err := api.NewClient(api.Config{
 		Address: promAddr,
 	})
@@ -27,37 +41,113 @@ func NewQueryClient(promAddr string) (*QueryClient, error) {
 		return nil, err
 	}
 	promV1 := v1.NewAPI(promClient)
-	return &QueryClient{prom: promV1}, nil
+	return &QueryClient{prom: promV1, query: query}, nil
 }
 
+// CheckQuery makes sure the queries return matrixes, and every series has the source and target labels; it returns
+// an InvalidQueryError if not.
+func (it *QueryClient) CheckQuery(ctx context.Context) error {
+	for _, template := range it.query.templates() {
+		query := it.query.Render(template)
+		now := time.Now()
+		matrix, err := it.queryRange(ctx, query, v1.Range{
+			Start: now.Add(-validationRange),
+			End:   now,
+			Step:  15 * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+		if len(matrix) == 0 {
+			log.L().With(zap.String("query", query)).Warn("query returns nothing currently")
+		}
+		for _, stream := range matrix {
+			for _, label := range []string{it.query.SourceLabel, it.query.TargetLabel} {
+				if _, ok := stream.Metric[model.LabelName(label)]; !ok {
+					return &InvalidQueryError{Query: query, Reason: fmt.Sprintf("series %s without label %s", stream.Metric.String(), label)}
+				}
+			}
+		}
+		log.L().With(zap.String("query", query)).With(zap.Int("series", len(matrix))).Info("query checked")
+	}
+	return nil
+}
+
+// FetchNodeLatencyMetrics fetches the latency of links, the samples are marked failed by SuccessQuery if present.
 func (it *QueryClient) FetchNodeLatencyMetrics(ctx context.Context, duration time.Duration) (map[Link]TimeSeries, error) {
 	now := time.Now()
 	// here is trick to avoid not enough samples during assertion on time series
 	duration = duration + time.Minute
-	values, err := it.prom.QueryRange(ctx, IcmpPingQuery, v1.Range{
+	queryRange := v1.Range{
 		Start: now.Add(-duration),
 		End:   now,
 		Step:  time.Second,
-	})
+	}
+	latency, err := it.queryRange(ctx, it.query.Render(it.query.LatencyQuery), queryRange)
+	if err != nil {
+		return nil, err
+	}
+	result := it.query.parseLatencyMatrix(latency)
+	if it.query.SuccessQuery == "" {
+		return result, nil
+	}
 
+	success, err := it.queryRange(ctx, it.query.Render(it.query.SuccessQuery), queryRange)
 	if err != nil {
 		return nil, err
 	}
+	for link, pairs := range it.query.groupByLink(success) {
+		if marked := markFailures(result[link], pairs); len(marked) > 0 {
+			result[link] = marked
+		}
+	}
+	return result, nil
+}
+
+func (it *QueryClient) queryRange(ctx context.Context, query string, queryRange v1.Range) (model.Matrix, error) {
+	values, err := it.prom.QueryRange(ctx, query, queryRange)
+	if err != nil {
+		if apiErr, ok := err.(*v1.Error); ok && apiErr.Type == v1.ErrBadData {
+			return nil, &InvalidQueryError{Query: query, Reason: apiErr.Error()}
+		}
+		return nil, fmt.Errorf("failed to execute query %s: %v", query, err)
+	}
 	switch values.Type() {
 	case model.ValMatrix:
-		result := make(map[Link]TimeSeries)
-		for _, matrix := range values.(model.Matrix) {
-			instance := string(matrix.Metric[LabelInstance])
-			if strings.Contains(instance, ":") {
-				instance = instance[:strings.LastIndex(instance, ":")]
-			}
-			result[Link{
-				From: instance,
-				To:   string(matrix.Metric[LabelPing]),
-			}] = parseTimeSeries(matrix.Values)
-		}
-		return result, nil
+		return values.(model.Matrix), nil
 	default:
-		return nil, fmt.Errorf("failed parse prometheus data with [%s]", values.Type().String())
+		return nil, &InvalidQueryError{Query: query, Reason: fmt.Sprintf("unexpected result type [%s]", values.Type().String())}
+	}
+}
+
+func (it QueryConfig) templates() []string {
+	if it.SuccessQuery == "" {
+		return []string{it.LatencyQuery}
+	}
+	return []string{it.LatencyQuery, it.SuccessQuery}
+}
+
+func (it QueryConfig) linkOf(metric model.Metric) Link {
+	return Link{
+		From: addrhelper.HostOf(string(metric[model.LabelName(it.SourceLabel)])),
+		To:   addrhelper.HostOf(string(metric[model.LabelName(it.TargetLabel)])),
+	}
+}
+
+// parseLatencyMatrix keys the time series by links between normalized hosts, the port of instance is dropped.
+func (it QueryConfig) parseLatencyMatrix(matrix model.Matrix) map[Link]TimeSeries {
+	result := make(map[Link]TimeSeries)
+	for _, stream := range matrix {
+		result[it.linkOf(stream.Metric)] = parseTimeSeries(stream.Values)
+	}
+	return result
+}
+
+func (it QueryConfig) groupByLink(matrix model.Matrix) map[Link][]model.SamplePair {
+	result := make(map[Link][]model.SamplePair)
+	for _, stream := range matrix {
+		link := it.linkOf(stream.Metric)
+		result[link] = append(result[link], stream.Values...)
 	}
+	return result
 }
@@ -0,0 +1,160 @@
+package promhelper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakePrometheus serves query_range with result, and records the last query.
+func newFakePrometheus(result string, lastQuery *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = r.ParseForm()
+		*lastQuery = r.Form.Get("query")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "data": ` + result + `}`))
+	}))
+}
+
+func TestQueryClient_CheckQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  string
+		wantErr bool
+	}{
+		{
+			name:   "matrix with labels",
+			result: `{"resultType": "matrix", "result": [{"metric": {"instance": "10.0.0.1:9100", "ping": "10.0.0.2"}, "values": [[1605602637, "0.001"]]}]}`,
+		}, {
+			name:   "empty matrix",
+			result: `{"resultType": "matrix", "result": []}`,
+		}, {
+			name:    "matrix without target label",
+			result:  `{"resultType": "matrix", "result": [{"metric": {"instance": "10.0.0.1:9100"}, "values": [[1605602637, "0.001"]]}]}`,
+			wantErr: true,
+		}, {
+			name:    "scalar",
+			result:  `{"resultType": "scalar", "result": [1605602637, "1"]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			server := newFakePrometheus(tt.result, &query)
+			defer server.Close()
+			client, err := NewQueryClient(server.URL, DefaultQueryConfig())
+			if err != nil {
+				t.Fatalf("NewQueryClient() error = %v", err)
+			}
+			err = client.CheckQuery(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, invalid := err.(*InvalidQueryError); invalid != tt.wantErr {
+				t.Errorf("CheckQuery() error = %v, should be an InvalidQueryError", err)
+			}
+		})
+	}
+}
+
+func TestQueryClient_CheckQueryUnreachable(t *testing.T) {
+	var query string
+	server := newFakePrometheus(`{"resultType": "matrix", "result": []}`, &query)
+	server.Close()
+	client, err := NewQueryClient(server.URL, DefaultQueryConfig())
+	if err != nil {
+		t.Fatalf("NewQueryClient() error = %v", err)
+	}
+	err = client.CheckQuery(context.Background())
+	if _, invalid := err.(*InvalidQueryError); err == nil || invalid {
+		t.Errorf("CheckQuery() error = %v, want an error other than InvalidQueryError", err)
+	}
+}
+
+func TestQueryClient_FetchNodeLatencyMetricsWithLabels(t *testing.T) {
+	var query string
+	server := newFakePrometheus(`{"resultType": "matrix", "result": [
+		{"metric": {"source": "10.0.0.1", "target": "10.0.0.2:20160"}, "values": [[1605602637, "0.001"], [1605602652, "0.002"]]}
+	]}`, &query)
+	defer server.Close()
+
+	client, err := NewQueryClient(server.URL, QueryConfig{
+		LatencyQuery: `probe_duration_seconds{module="tcp_connect",$matchers}`,
+		SourceLabel:  "source",
+		TargetLabel:  "target",
+		Matchers:     []string{`cluster="prod-a"`},
+	})
+	if err != nil {
+		t.Fatalf("NewQueryClient() error = %v", err)
+	}
+	metrics, err := client.FetchNodeLatencyMetrics(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("FetchNodeLatencyMetrics() error = %v", err)
+	}
+	if want := `probe_duration_seconds{module="tcp_connect",cluster="prod-a"}`; query != want {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+	if ts, ok := metrics[Link{From: "10.0.0.1", To: "10.0.0.2"}]; !ok || len(ts) != 2 {
+		t.Errorf("FetchNodeLatencyMetrics() = %v", metrics)
+	}
+}
+
+func TestQueryClient_FetchNodeLatencyMetricsWithSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.Form.Get("query"), "probe_success") {
+			// 10.0.0.3 is unreachable, and probes to it never report latency
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"instance": "10.0.0.1:9100", "ping": "10.0.0.2"}, "values": [[1605602637, "1"], [1605602652, "0"]]},
+				{"metric": {"instance": "10.0.0.1:9100", "ping": "10.0.0.3"}, "values": [[1605602637, "0"], [1605602652, "0"]]}
+			]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [
+			{"metric": {"instance": "10.0.0.1:9100", "ping": "10.0.0.2"}, "values": [[1605602637, "0.001"], [1605602652, "0.0005"]]}
+		]}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewQueryClient(server.URL, DefaultQueryConfig())
+	if err != nil {
+		t.Fatalf("NewQueryClient() error = %v", err)
+	}
+	metrics, err := client.FetchNodeLatencyMetrics(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("FetchNodeLatencyMetrics() error = %v", err)
+	}
+	want := map[Link]TimeSeries{
+		{From: "10.0.0.1", To: "10.0.0.2"}: {
+			{Timestamp: time.Unix(1605602637, 0), Latency: time.Millisecond},
+			{Timestamp: time.Unix(1605602652, 0), Latency: 500 * time.Microsecond, Failed: true},
+		},
+		{From: "10.0.0.1", To: "10.0.0.3"}: {
+			{Timestamp: time.Unix(1605602637, 0), Failed: true},
+			{Timestamp: time.Unix(1605602652, 0), Failed: true},
+		},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("FetchNodeLatencyMetrics() = %v, want %v", metrics, want)
+	}
+	for link, series := range want {
+		got := metrics[link]
+		if len(got) != len(series) {
+			t.Fatalf("series of %v = %v, want %v", link, got, series)
+		}
+		for i := range series {
+			if !got[i].Timestamp.Equal(series[i].Timestamp) || got[i].Latency != series[i].Latency || got[i].Failed != series[i].Failed {
+				t.Errorf("series of %v = %v, want %v", link, got, series)
+			}
+		}
+	}
+}
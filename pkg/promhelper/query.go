@@ -0,0 +1,78 @@
+package promhelper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MatchersPlaceholder in query templates is replaced with the extra label matchers joined by comma.
+const MatchersPlaceholder = "$matchers"
+
+// DefaultLatencyQuery is the metric exposed by blackbox_exporter with icmp probes.
+const DefaultLatencyQuery = "probe_duration_seconds{ping!=\"\"," + MatchersPlaceholder + "}"
+
+// DefaultSuccessQuery is the result of the same probes, 0 for failures.
+const DefaultSuccessQuery = "probe_success{ping!=\"\"," + MatchersPlaceholder + "}"
+
+const DefaultSourceLabel = "instance"
+const DefaultTargetLabel = "ping"
+
+var matcherPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*\s*(=|!=|=~|!~)\s*"(?:[^"\\]|\\.)*"$`)
+
+// QueryConfig configures the query of latency, and the labels of the source and the target of links.
+type QueryConfig struct {
+	// LatencyQuery is a template of PromQL, which returns latency in seconds.
+	LatencyQuery string
+	// SuccessQuery is a template of PromQL, which returns 0 for failed probes on the same links; empty disables it.
+	SuccessQuery string
+	SourceLabel  string
+	TargetLabel  string
+	// Matchers are extra label matchers like `cluster="prod-a"`, which replace MatchersPlaceholder in templates.
+	Matchers []string
+}
+
+// DefaultQueryConfig works with blackbox_exporter deployed by tidb-ansible.
+func DefaultQueryConfig() QueryConfig {
+	return QueryConfig{
+		LatencyQuery: DefaultLatencyQuery,
+		SuccessQuery: DefaultSuccessQuery,
+		SourceLabel:  DefaultSourceLabel,
+		TargetLabel:  DefaultTargetLabel,
+	}
+}
+
+// Validate checks the label names and matchers, and that templates could take the matchers.
+func (it QueryConfig) Validate() error {
+	if it.LatencyQuery == "" {
+		return fmt.Errorf("latency query is empty")
+	}
+	if it.SourceLabel == "" || it.TargetLabel == "" {
+		return fmt.Errorf("source label and target label are required")
+	}
+	if it.SourceLabel == it.TargetLabel {
+		return fmt.Errorf("source label and target label should be different")
+	}
+	for _, matcher := range it.Matchers {
+		if !matcherPattern.MatchString(strings.TrimSpace(matcher)) {
+			return fmt.Errorf("invalid label matcher %s", matcher)
+		}
+	}
+	if len(it.Matchers) > 0 && !strings.Contains(it.LatencyQuery, MatchersPlaceholder) {
+		return fmt.Errorf("latency query should contain %s for label matchers", MatchersPlaceholder)
+	}
+	if len(it.Matchers) > 0 && it.SuccessQuery != "" && !strings.Contains(it.SuccessQuery, MatchersPlaceholder) {
+		return fmt.Errorf("success query should contain %s for label matchers", MatchersPlaceholder)
+	}
+	return nil
+}
+
+// Render replaces MatchersPlaceholder in template with matchers; an empty placeholder leaves a trailing comma
+// like `{ping!="",}`, which is valid in PromQL.
+func (it QueryConfig) Render(template string) string {
+	var matchers []string
+	for _, matcher := range it.Matchers {
+		matchers = append(matchers, strings.TrimSpace(matcher))
+	}
+	return strings.Replace(template, MatchersPlaceholder, strings.Join(matchers, ","), -1)
+}
@@ -0,0 +1,55 @@
+package promhelper
+
+import "testing"
+
+func TestQueryConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(config *QueryConfig)
+		wantErr bool
+	}{
+		{"default", func(config *QueryConfig) {}, false},
+		{"matchers", func(config *QueryConfig) { config.Matchers = []string{`cluster="prod-a"`, `module=~"icmp|tcp"`} }, false},
+		{"escaped quote in matcher", func(config *QueryConfig) { config.Matchers = []string{`job!="a\"b"`} }, false},
+		{"matcher without quotes", func(config *QueryConfig) { config.Matchers = []string{`cluster=prod-a`} }, true},
+		{"matcher with invalid label", func(config *QueryConfig) { config.Matchers = []string{`1cluster="prod-a"`} }, true},
+		{"matchers without placeholder", func(config *QueryConfig) {
+			config.LatencyQuery = `probe_duration_seconds{ping!=""}`
+			config.Matchers = []string{`cluster="prod-a"`}
+		}, true},
+		{"empty query", func(config *QueryConfig) { config.LatencyQuery = "" }, true},
+		{"empty label", func(config *QueryConfig) { config.TargetLabel = "" }, true},
+		{"same labels", func(config *QueryConfig) { config.TargetLabel = config.SourceLabel }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultQueryConfig()
+			tt.modify(&config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryConfig_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		matchers []string
+		want     string
+	}{
+		{"default without matchers", DefaultLatencyQuery, nil, `probe_duration_seconds{ping!="",}`},
+		{"default with matchers", DefaultLatencyQuery, []string{`cluster="prod-a"`, ` module="icmp" `}, `probe_duration_seconds{ping!="",cluster="prod-a",module="icmp"}`},
+		{"tcp probes", `probe_duration_seconds{job="tcp",$matchers} * 2`, []string{`cluster="prod-a"`}, `probe_duration_seconds{job="tcp",cluster="prod-a"} * 2`},
+		{"without placeholder", `probe_duration_seconds`, nil, `probe_duration_seconds`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := QueryConfig{Matchers: tt.matchers}
+			if got := config.Render(tt.template); got != tt.want {
+				t.Errorf("Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package promhelper
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The modes of Rule.
+const (
+	// RuleAll requires every sample to pass the threshold, it is parsed as the zero value of Rule.
+	RuleAll = "all"
+	// RuleFraction requires at least Value percent of samples to pass the threshold, written as "95%".
+	RuleFraction = "fraction"
+	// RulePercentile requires the Value-th percentile of samples to pass the threshold, written as "p90".
+	RulePercentile = "percentile"
+)
+
+// Rule decides whether the samples in a window pass a latency threshold; failed samples are slower than any threshold.
+// It could be used as a flag value.
+type Rule struct {
+	Mode  string
+	Value float64
+}
+
+func ParseRule(text string) (Rule, error) {
+	text = strings.TrimSpace(text)
+	var result Rule
+	var value string
+	switch {
+	case text == RuleAll || text == "":
+		return Rule{}, nil
+	case strings.HasSuffix(text, "%"):
+		result.Mode, value = RuleFraction, strings.TrimSuffix(text, "%")
+	case strings.HasPrefix(text, "p"):
+		result.Mode, value = RulePercentile, strings.TrimPrefix(text, "p")
+	default:
+		return Rule{}, fmt.Errorf("invalid rule %s, it should be all, a percentage like 95%% or a percentile like p90", text)
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 || parsed > 100 {
+		return Rule{}, fmt.Errorf("invalid rule %s, the value should be in (0, 100]", text)
+	}
+	result.Value = parsed
+	return result, nil
+}
+
+func (it Rule) String() string {
+	switch it.Mode {
+	case RuleFraction:
+		return strconv.FormatFloat(it.Value, 'f', -1, 64) + "%"
+	case RulePercentile:
+		return "p" + strconv.FormatFloat(it.Value, 'f', -1, 64)
+	default:
+		return RuleAll
+	}
+}
+
+func (it *Rule) Set(text string) error {
+	parsed, err := ParseRule(text)
+	if err != nil {
+		return err
+	}
+	*it = parsed
+	return nil
+}
+
+func (it *Rule) Type() string {
+	return "rule"
+}
+
+func (it Rule) MarshalText() ([]byte, error) {
+	return []byte(it.String()), nil
+}
+
+func (it *Rule) UnmarshalText(text []byte) error {
+	return it.Set(string(text))
+}
+
+// LatencyLargerThanThresholdBy reports whether the samples in the last atLeastFor are larger than threshold by rule.
+func (it *TimeSeries) LatencyLargerThanThresholdBy(rule Rule, threshold, atLeastFor time.Duration) bool {
+	switch rule.Mode {
+	case RuleFraction:
+		samples, ok := it.window(atLeastFor)
+		if !ok {
+			return false
+		}
+		var count int
+		for _, sample := range samples {
+			if sample.Failed || sample.Latency > threshold {
+				count++
+			}
+		}
+		return float64(count)*100 >= rule.Value*float64(len(samples))
+	case RulePercentile:
+		samples, ok := it.window(atLeastFor)
+		return ok && percentile(samples, rule.Value) > threshold
+	default:
+		return it.LatencyLargerThanThresholdFor(threshold, atLeastFor)
+	}
+}
+
+// LatencySmallerThanThresholdBy reports whether the samples in the last atLeastFor are smaller than threshold by rule.
+func (it *TimeSeries) LatencySmallerThanThresholdBy(rule Rule, threshold, atLeastFor time.Duration) bool {
+	switch rule.Mode {
+	case RuleFraction:
+		samples, ok := it.window(atLeastFor)
+		if !ok {
+			return false
+		}
+		var count int
+		for _, sample := range samples {
+			if !sample.Failed && sample.Latency < threshold {
+				count++
+			}
+		}
+		return float64(count)*100 >= rule.Value*float64(len(samples))
+	case RulePercentile:
+		samples, ok := it.window(atLeastFor)
+		return ok && percentile(samples, rule.Value) < threshold
+	default:
+		return it.LatencySmallerThanThresholdFor(threshold, atLeastFor)
+	}
+}
+
+// window returns the samples in the last atLeastFor, it is false if the time series does not cover atLeastFor.
+func (it *TimeSeries) window(atLeastFor time.Duration) ([]Sample, bool) {
+	if len(*it) < 2 {
+		return nil, false
+	}
+	if atLeastFor > ((*it)[len(*it)-1].Timestamp.Sub((*it)[0].Timestamp)) {
+		return nil, false
+	}
+	start := (*it)[len(*it)-1].Timestamp.Add(-atLeastFor)
+	var result []Sample
+	for _, sample := range *it {
+		if !sample.Timestamp.Before(start) {
+			result = append(result, sample)
+		}
+	}
+	return result, true
+}
+
+// percentile returns the nearest-rank percentile of latency, failed samples are the slowest.
+func percentile(samples []Sample, value float64) time.Duration {
+	latencies := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		if sample.Failed {
+			latencies[i] = time.Duration(math.MaxInt64)
+		} else {
+			latencies[i] = sample.Latency
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(value / 100 * float64(len(latencies))))
+	if rank < 1 {
+		rank = 1
+	}
+	return latencies[rank-1]
+}
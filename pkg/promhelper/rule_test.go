@@ -0,0 +1,94 @@
+package promhelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Rule
+		wantErr bool
+	}{
+		{"", Rule{}, false},
+		{"all", Rule{}, false},
+		{"90%", Rule{Mode: RuleFraction, Value: 90}, false},
+		{"p99.9", Rule{Mode: RulePercentile, Value: 99.9}, false},
+		{"p0", Rule{}, true},
+		{"101%", Rule{}, true},
+		{"pxx", Rule{}, true},
+		{"most", Rule{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseRule(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRule() = %v, want %v", got, tt.want)
+			}
+			if err == nil && tt.text != "" && got.String() != tt.text {
+				t.Errorf("String() = %v, want %v", got.String(), tt.text)
+			}
+		})
+	}
+}
+
+func TestTimeSeries_ThresholdBy(t *testing.T) {
+	now := time.Now()
+	// one sample every 10s for 90s, the samples in slow are 2s and the others are 10ms, failed if the index is in failed
+	series := func(slow []int, failed []int) TimeSeries {
+		var result TimeSeries
+		for i := 0; i < 10; i++ {
+			sample := Sample{Timestamp: now.Add(time.Duration(i-9) * 10 * time.Second), Latency: 10 * time.Millisecond}
+			for _, index := range slow {
+				if index == i {
+					sample.Latency = 2 * time.Second
+				}
+			}
+			for _, index := range failed {
+				if index == i {
+					sample.Failed = true
+				}
+			}
+			result = append(result, sample)
+		}
+		return result
+	}
+	all := Rule{}
+	fraction := Rule{Mode: RuleFraction, Value: 80}
+	p90 := Rule{Mode: RulePercentile, Value: 90}
+	tests := []struct {
+		name        string
+		it          TimeSeries
+		rule        Rule
+		wantLarger  bool
+		wantSmaller bool
+	}{
+		{"all with one fast sample", series([]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, nil), all, false, false},
+		{"all slow", series([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, nil), all, true, false},
+		{"all fast", series(nil, nil), all, false, true},
+		{"fraction with one fast sample", series([]int{0, 1, 2, 3, 4, 5, 6, 7, 8}, nil), fraction, true, false},
+		{"fraction with failures", series([]int{0, 1, 2, 3, 4, 5}, []int{6, 7}), fraction, true, false},
+		{"fraction under", series([]int{0, 1, 2, 3, 4, 5, 6}, nil), fraction, false, false},
+		{"fraction with one spike", series([]int{4}, nil), fraction, false, true},
+		{"percentile with one spike", series([]int{4}, nil), p90, false, true},
+		{"percentile with two spikes", series([]int{4, 7}, nil), p90, true, false},
+		{"percentile with one failure", series(nil, []int{4}), p90, false, true},
+		{"percentile with two failures", series(nil, []int{4, 7}), p90, true, false},
+		{"window out of range", series(nil, nil)[:3], p90, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.it.LatencyLargerThanThresholdBy(tt.rule, time.Second, 90*time.Second); got != tt.wantLarger {
+				t.Errorf("LatencyLargerThanThresholdBy() = %v, want %v", got, tt.wantLarger)
+			}
+			if got := tt.it.LatencySmallerThanThresholdBy(tt.rule, time.Second, 90*time.Second); got != tt.wantSmaller {
+				t.Errorf("LatencySmallerThanThresholdBy() = %v, want %v", got, tt.wantSmaller)
+			}
+		})
+	}
+}
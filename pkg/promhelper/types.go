@@ -2,12 +2,15 @@ package promhelper
 
 import (
 	"github.com/prometheus/common/model"
+	"sort"
 	"time"
 )
 
 type Sample struct {
 	Timestamp time.Time
 	Latency   time.Duration
+	// Failed is true if the probe failed, the sample exceeds any threshold regardless of Latency.
+	Failed bool
 }
 
 type Link struct {
@@ -29,6 +32,34 @@ func parseTimeSeries(origin []model.SamplePair) TimeSeries {
 
 type TimeSeries []Sample
 
+// markFailures marks the samples failed if probe_success is 0 at the same time, a failed probe without latency
+// is added as a failed sample.
+func markFailures(series TimeSeries, success []model.SamplePair) TimeSeries {
+	failed := make(map[model.Time]bool)
+	for _, pair := range success {
+		if pair.Value == 0 {
+			failed[pair.Timestamp] = true
+		}
+	}
+	if len(failed) == 0 {
+		return series
+	}
+	result := make(TimeSeries, 0, len(series))
+	for _, sample := range series {
+		timestamp := model.TimeFromUnixNano(sample.Timestamp.UnixNano())
+		if failed[timestamp] {
+			sample.Failed = true
+			delete(failed, timestamp)
+		}
+		result = append(result, sample)
+	}
+	for timestamp := range failed {
+		result = append(result, Sample{Timestamp: timestamp.Time(), Failed: true})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return result
+}
+
 func (it *TimeSeries) LatencyLargerThanThresholdFor(threshold, atLeastFor time.Duration) bool {
 	if len(*it) < 2 {
 		return false
@@ -42,7 +73,7 @@ func (it *TimeSeries) LatencyLargerThanThresholdFor(threshold, atLeastFor time.D
 		if sample.Timestamp.Before(start) {
 			continue
 		}
-		if sample.Latency > threshold {
+		if sample.Failed || sample.Latency > threshold {
 			continue
 		} else {
 			return false
@@ -64,7 +95,7 @@ func (it *TimeSeries) LatencySmallerThanThresholdFor(threshold, atLeastFor time.
 		if sample.Timestamp.Before(start) {
 			continue
 		}
-		if sample.Latency < threshold {
+		if !sample.Failed && sample.Latency < threshold {
 			continue
 		} else {
 			return false
@@ -72,3 +103,112 @@ func (it *TimeSeries) LatencySmallerThanThresholdFor(threshold, atLeastFor time.
 	}
 	return true
 }
+
+// StaleAt reports whether the time series is empty, or its last sample is older than maxAge at now;
+// maxAge <= 0 only treats empty time series as stale.
+func (it *TimeSeries) StaleAt(now time.Time, maxAge time.Duration) bool {
+	if len(*it) == 0 {
+		return true
+	}
+	if maxAge <= 0 {
+		return false
+	}
+	return now.Sub((*it)[len(*it)-1].Timestamp) > maxAge
+}
+
+// LossRatioFor returns the ratio of failed samples in the last atLeastFor, it is false if the time series
+// does not cover atLeastFor.
+func (it *TimeSeries) LossRatioFor(atLeastFor time.Duration) (float64, bool) {
+	if len(*it) < 2 {
+		return 0, false
+	}
+	if atLeastFor > ((*it)[len(*it)-1].Timestamp.Sub((*it)[0].Timestamp)) {
+		return 0, false
+	}
+	start := (*it)[len(*it)-1].Timestamp.Add(-atLeastFor)
+	var total, failed int
+	for _, sample := range *it {
+		if sample.Timestamp.Before(start) {
+			continue
+		}
+		total++
+		if sample.Failed {
+			failed++
+		}
+	}
+	return float64(failed) / float64(total), true
+}
+
+// CappedMeanLatencyFor returns the mean latency in the last atLeastFor, each latency is capped at limit and failed
+// samples count as limit; it is false if the time series does not cover atLeastFor.
+func (it *TimeSeries) CappedMeanLatencyFor(atLeastFor, limit time.Duration) (time.Duration, bool) {
+	samples, ok := it.window(atLeastFor)
+	if !ok {
+		return 0, false
+	}
+	var sum time.Duration
+	for _, sample := range samples {
+		if sample.Failed || sample.Latency > limit {
+			sum += limit
+		} else {
+			sum += sample.Latency
+		}
+	}
+	return sum / time.Duration(len(samples)), true
+}
+
+// ExceedingFor returns how long the latest samples keep larger than threshold, failed samples exceed any threshold.
+func (it *TimeSeries) ExceedingFor(threshold time.Duration) time.Duration {
+	if len(*it) == 0 {
+		return 0
+	}
+	last := (*it)[len(*it)-1].Timestamp
+	since := last
+	for i := len(*it) - 1; i >= 0; i-- {
+		sample := (*it)[i]
+		if !sample.Failed && sample.Latency <= threshold {
+			break
+		}
+		since = sample.Timestamp
+	}
+	return last.Sub(since)
+}
+
+// LatencySummary summarizes the samples of a TimeSeries, the latency of failed samples is excluded.
+type LatencySummary struct {
+	Samples  int           `json:"samples"`
+	Failures int           `json:"failures"`
+	Last     time.Duration `json:"last"`
+	Min      time.Duration `json:"min"`
+	Max      time.Duration `json:"max"`
+	Mean     time.Duration `json:"mean"`
+}
+
+func (it *TimeSeries) Summary() LatencySummary {
+	var result LatencySummary
+	if len(*it) == 0 {
+		return result
+	}
+	var sum time.Duration
+	var succeeded int
+	result.Samples = len(*it)
+	for _, sample := range *it {
+		if sample.Failed {
+			result.Failures++
+			continue
+		}
+		if succeeded == 0 || sample.Latency < result.Min {
+			result.Min = sample.Latency
+		}
+		if succeeded == 0 || sample.Latency > result.Max {
+			result.Max = sample.Latency
+		}
+		result.Last = sample.Latency
+		sum += sample.Latency
+		succeeded++
+	}
+	if succeeded > 0 {
+		result.Mean = sum / time.Duration(succeeded)
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package promhelper
 
 import (
+	"github.com/prometheus/common/model"
 	"math/rand"
 	"testing"
 	"time"
@@ -249,3 +250,215 @@ func TestTimeSeries_LatencySmallerThanThresholdFor(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeSeries_Summary(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		it   TimeSeries
+		want LatencySummary
+	}{
+		{
+			"nil time series",
+			nil,
+			LatencySummary{},
+		}, {
+			"normal situation",
+			[]Sample{
+				{Timestamp: now.Add(-30 * time.Second), Latency: 2 * time.Millisecond},
+				{Timestamp: now.Add(-15 * time.Second), Latency: 6 * time.Millisecond},
+				{Timestamp: now, Latency: time.Millisecond},
+			},
+			LatencySummary{
+				Samples: 3,
+				Last:    time.Millisecond,
+				Min:     time.Millisecond,
+				Max:     6 * time.Millisecond,
+				Mean:    3 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.it.Summary(); got != tt.want {
+				t.Errorf("Summary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseLatencyMatrix(t *testing.T) {
+	now := model.Now()
+	stream := func(instance, ping string) *model.SampleStream {
+		return &model.SampleStream{
+			Metric: model.Metric{DefaultSourceLabel: model.LabelValue(instance), DefaultTargetLabel: model.LabelValue(ping)},
+			Values: []model.SamplePair{{Timestamp: now, Value: 0.001}},
+		}
+	}
+	tests := []struct {
+		name   string
+		stream *model.SampleStream
+		want   Link
+	}{
+		{"ipv4", stream("10.0.0.1:9100", "10.0.0.2"), Link{From: "10.0.0.1", To: "10.0.0.2"}},
+		{"ipv4 without port", stream("10.0.0.1", "10.0.0.2"), Link{From: "10.0.0.1", To: "10.0.0.2"}},
+		{"hostname", stream("TiKV-0:9100", "tikv-1."), Link{From: "tikv-0", To: "tikv-1"}},
+		{"bracketed ipv6", stream("[2001:db8::1]:9100", "[2001:db8::2]"), Link{From: "2001:db8::1", To: "2001:db8::2"}},
+		{"bare ipv6", stream("2001:db8::1", "2001:DB8:0::2"), Link{From: "2001:db8::1", To: "2001:db8::2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DefaultQueryConfig().parseLatencyMatrix(model.Matrix{tt.stream})
+			if _, ok := got[tt.want]; !ok || len(got) != 1 {
+				t.Errorf("parseLatencyMatrix() = %v, want link %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSeries_FailedSamples(t *testing.T) {
+	now := time.Now()
+	// failed probes are fast, but they exceed any threshold
+	series := TimeSeries{
+		{Timestamp: now.Add(-30 * time.Second), Latency: time.Millisecond, Failed: true},
+		{Timestamp: now.Add(-15 * time.Second), Latency: time.Millisecond, Failed: true},
+		{Timestamp: now, Latency: 2 * time.Second},
+	}
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"larger than threshold", series.LatencyLargerThanThresholdFor(time.Second, 30*time.Second), true},
+		{"smaller than threshold", series.LatencySmallerThanThresholdFor(time.Hour, 30*time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+	want := LatencySummary{Samples: 3, Failures: 2, Last: 2 * time.Second, Min: 2 * time.Second, Max: 2 * time.Second, Mean: 2 * time.Second}
+	if got := series.Summary(); got != want {
+		t.Errorf("Summary() = %v, want %v", got, want)
+	}
+}
+
+func TestTimeSeries_LossRatioFor(t *testing.T) {
+	now := time.Now()
+	series := func(failed ...bool) TimeSeries {
+		var result TimeSeries
+		for i, item := range failed {
+			result = append(result, Sample{Timestamp: now.Add(time.Duration(i-len(failed)+1) * 15 * time.Second), Latency: time.Millisecond, Failed: item})
+		}
+		return result
+	}
+	tests := []struct {
+		name       string
+		it         TimeSeries
+		atLeastFor time.Duration
+		want       float64
+		wantOk     bool
+	}{
+		{"nil time series", nil, time.Minute, 0, false},
+		{"not enough samples", series(true, true), time.Minute, 0, false},
+		{"no loss", series(false, false, false, false, false), time.Minute, 0, true},
+		{"partial loss", series(false, true, false, false, true), time.Minute, 0.4, true},
+		{"loss out of window", series(true, true, false, false, false, false), 45 * time.Second, 0, true},
+		{"all lost", series(true, true, true, true, true), time.Minute, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.it.LossRatioFor(tt.atLeastFor)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("LossRatioFor() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTimeSeries_StaleAt(t *testing.T) {
+	now := time.Now()
+	series := TimeSeries{
+		{Timestamp: now.Add(-3 * time.Minute), Latency: time.Millisecond},
+		{Timestamp: now.Add(-2 * time.Minute), Latency: time.Millisecond},
+	}
+	tests := []struct {
+		name   string
+		it     TimeSeries
+		maxAge time.Duration
+		want   bool
+	}{
+		{"nil time series", nil, time.Minute, true},
+		{"nil time series without max age", nil, 0, true},
+		{"stopped updating", series, time.Minute, true},
+		{"fresh", series, 5 * time.Minute, false},
+		{"without max age", series, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.it.StaleAt(now, tt.maxAge); got != tt.want {
+				t.Errorf("StaleAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSeries_CappedMeanLatencyFor(t *testing.T) {
+	now := time.Now()
+	it := TimeSeries{
+		{Timestamp: now.Add(-3 * time.Minute), Latency: 10 * time.Second},
+		{Timestamp: now.Add(-time.Minute), Latency: time.Second},
+		{Timestamp: now.Add(-30 * time.Second), Latency: 10 * time.Second},
+		{Timestamp: now, Failed: true},
+	}
+	tests := []struct {
+		name       string
+		atLeastFor time.Duration
+		want       time.Duration
+		wantOk     bool
+	}{
+		{"capped and failed", time.Minute, 5 * time.Second / 3, true},
+		{"not covered", 5 * time.Minute, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := it.CappedMeanLatencyFor(tt.atLeastFor, 2*time.Second)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("CappedMeanLatencyFor() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTimeSeries_ExceedingFor(t *testing.T) {
+	now := time.Now()
+	series := func(latencies ...time.Duration) TimeSeries {
+		var result TimeSeries
+		for i, latency := range latencies {
+			result = append(result, Sample{Timestamp: now.Add(time.Duration(i-len(latencies)+1) * 15 * time.Second), Latency: latency})
+		}
+		return result
+	}
+	failed := series(time.Millisecond, 2*time.Second, time.Millisecond)
+	failed[2].Failed = true
+	tests := []struct {
+		name string
+		it   TimeSeries
+		want time.Duration
+	}{
+		{"empty", nil, 0},
+		{"fast", series(time.Millisecond, time.Millisecond), 0},
+		{"single slow sample", series(time.Millisecond, 2*time.Second), 0},
+		{"slow for 30s", series(2*time.Second, time.Millisecond, 2*time.Second, 2*time.Second, 2*time.Second), 30 * time.Second},
+		{"failed", failed, 15 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.it.ExceedingFor(time.Second); got != tt.want {
+				t.Errorf("ExceedingFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
+	"context"
+	"encoding/json"
+)
+
+const DefaultEtcdKey = "/auto-failover-tikv-leader-evict/state"
+
+// EtcdStore keeps the State as a json value in etcd, like the one embedded in PD,
+// so it is shared by evictor replicas and survives the loss of a host.
+type EtcdStore struct {
+	client *etcdhelper.Client
+	key    string
+}
+
+func NewEtcdStore(client *etcdhelper.Client, key string) *EtcdStore {
+	return &EtcdStore{client: client, key: key}
+}
+
+func (it *EtcdStore) Load() (*State, error) {
+	value, found, err := it.client.Get(context.Background(), it.key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return NewState(), nil
+	}
+	return decode([]byte(value.Value))
+}
+
+func (it *EtcdStore) Save(state *State) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return it.client.Put(context.Background(), it.key, string(content), 0)
+}
@@ -0,0 +1,188 @@
+package state
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"sort"
+	"time"
+)
+
+// maxHistory is the max number of events kept for each store.
+const maxHistory = 32
+
+const (
+	EventEvicted        = "evicted"
+	EventRecovered      = "recovered"
+	EventForgotten      = "forgotten"
+	EventWeightLowered  = "weight-lowered"
+	EventWeightRestored = "weight-restored"
+	EventFlapped        = "flapped"
+)
+
+// Event is a change made on a store, by evictor or observed from pd.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Action string    `json:"action,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// StoreState is what evictor knows about a store across restarts.
+type StoreState struct {
+	Id      uint   `json:"id"`
+	Address string `json:"address"`
+	// Action is the action applied on the store by evictor itself; empty means it is not owned by evictor.
+	Action      string    `json:"action,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	EvictedAt   time.Time `json:"evicted_at,omitempty"`
+	RecoveredAt time.Time `json:"recovered_at,omitempty"`
+	// Evictions counts how many times the store has been evicted by evictor.
+	Evictions uint `json:"evictions"`
+	// Flaps counts how many times the store has been evicted again soon after recovered, until FlappedAt.
+	Flaps     uint      `json:"flaps,omitempty"`
+	FlappedAt time.Time `json:"flapped_at,omitempty"`
+	// OriginalWeight is the weight before evictor lowered its leader weight; nil means not lowered.
+	OriginalWeight *pdhelper.StoreWeight `json:"original_weight,omitempty"`
+	History        []Event               `json:"history,omitempty"`
+}
+
+// FlapsAt returns Flaps at now, one flap is forgiven for each decay since FlappedAt; decay <= 0 never forgives.
+func (it *StoreState) FlapsAt(now time.Time, decay time.Duration) uint {
+	if decay <= 0 || it.Flaps == 0 {
+		return it.Flaps
+	}
+	forgiven := uint(now.Sub(it.FlappedAt) / decay)
+	if forgiven >= it.Flaps {
+		return 0
+	}
+	return it.Flaps - forgiven
+}
+
+func (it *StoreState) record(event Event) {
+	it.History = append(it.History, event)
+	if len(it.History) > maxHistory {
+		it.History = it.History[len(it.History)-maxHistory:]
+	}
+}
+
+// State is the whole state of evictor, which is persisted by a Store.
+type State struct {
+	Stores map[uint]*StoreState `json:"stores"`
+	// SlowStoreSchedulerCreated is true if the cluster-wide evict-slow-store-scheduler is added by evictor itself, so
+	// it is removed by evictor only in this case.
+	SlowStoreSchedulerCreated bool `json:"slow_store_scheduler_created,omitempty"`
+}
+
+func NewState() *State {
+	return &State{Stores: make(map[uint]*StoreState)}
+}
+
+func (it *State) store(store pdhelper.Store) *StoreState {
+	item, ok := it.Stores[store.Id]
+	if !ok {
+		item = &StoreState{Id: store.Id}
+		it.Stores[store.Id] = item
+	}
+	if store.Address != "" {
+		item.Address = store.Address
+	}
+	return item
+}
+
+// Owns checks whether the store is mitigated by the action of evictor itself.
+func (it *State) Owns(action string, storeId uint) bool {
+	item, ok := it.Stores[storeId]
+	return ok && item.Action == action
+}
+
+// Owned returns the stores mitigated by the action of evictor itself, ordered by id.
+func (it *State) Owned(action string) []pdhelper.Store {
+	var result []pdhelper.Store
+	for _, item := range it.Stores {
+		if item.Action == action {
+			result = append(result, pdhelper.Store{Id: item.Id, Address: item.Address})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+func (it *State) RecordEvicted(store pdhelper.Store, action, reason string, now time.Time) {
+	item := it.store(store)
+	item.Action = action
+	item.Reason = reason
+	item.EvictedAt = now
+	item.Evictions++
+	item.record(Event{Time: now, Type: EventEvicted, Action: action, Reason: reason})
+}
+
+// RecordFlapped counts a flap of the store, after forgiving the flaps by decay.
+func (it *State) RecordFlapped(store pdhelper.Store, decay time.Duration, now time.Time) {
+	item := it.store(store)
+	item.Flaps = item.FlapsAt(now, decay) + 1
+	item.FlappedAt = now
+	item.record(Event{Time: now, Type: EventFlapped})
+}
+
+func (it *State) RecordRecovered(store pdhelper.Store, now time.Time) {
+	item := it.store(store)
+	item.record(Event{Time: now, Type: EventRecovered, Action: item.Action})
+	item.Action = ""
+	item.Reason = ""
+	item.RecoveredAt = now
+}
+
+// Forget drops the ownership of a store which is not mitigated any more, e.g. recovered by operators manually.
+func (it *State) Forget(store pdhelper.Store, reason string, now time.Time) {
+	item := it.store(store)
+	item.record(Event{Time: now, Type: EventForgotten, Action: item.Action, Reason: reason})
+	item.Action = ""
+	item.Reason = ""
+}
+
+func (it *State) RecordWeightLowered(store pdhelper.Store, original pdhelper.StoreWeight, now time.Time) {
+	item := it.store(store)
+	item.OriginalWeight = &original
+	item.record(Event{Time: now, Type: EventWeightLowered})
+}
+
+func (it *State) RecordWeightRestored(store pdhelper.Store, now time.Time) {
+	item := it.store(store)
+	item.OriginalWeight = nil
+	item.record(Event{Time: now, Type: EventWeightRestored})
+}
+
+// LoweredWeights returns the original weights of stores whose leader weight is lowered by evictor.
+func (it *State) LoweredWeights() map[uint]pdhelper.StoreWeight {
+	result := make(map[uint]pdhelper.StoreWeight)
+	for id, item := range it.Stores {
+		if item.OriginalWeight != nil {
+			result[id] = *item.OriginalWeight
+		}
+	}
+	return result
+}
+
+// Reconcile forgets owned stores which are not in applied any more, and returns them.
+// applied is the store ids keyed by action name, which are listed from pd.
+func (it *State) Reconcile(applied map[string][]uint, now time.Time) []pdhelper.Store {
+	var forgotten []pdhelper.Store
+	for _, item := range it.Stores {
+		if item.Action == "" || containsId(applied[item.Action], item.Id) {
+			continue
+		}
+		store := pdhelper.Store{Id: item.Id, Address: item.Address}
+		it.Forget(store, "not mitigated on pd any more", now)
+		forgotten = append(forgotten, store)
+	}
+	sort.Slice(forgotten, func(i, j int) bool { return forgotten[i].Id < forgotten[j].Id })
+	return forgotten
+}
+
+func containsId(ids []uint, target uint) bool {
+	for _, id := range ids {
+		if id == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package state
+
+import (
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper"
+	"auto-failover-tikv-leader-evict/pkg/etcdhelper/etcdtest"
+	"auto-failover-tikv-leader-evict/pkg/pdhelper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "evictor-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := etcdtest.NewServer()
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{name: "memory", store: NewStore("")},
+		{name: "file", store: NewStore(filepath.Join(dir, "state.json"))},
+		{name: "etcd", store: NewEtcdStore(etcdhelper.NewClient(server.URL), DefaultEtcdKey)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSaveAndLoad(t, tt.store)
+		})
+	}
+}
+
+func testSaveAndLoad(t *testing.T, store Store) {
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded.Stores) != 0 {
+		t.Fatalf("Load() = %v, want empty state", loaded)
+	}
+
+	now := time.Date(2020, 11, 17, 8, 0, 0, 0, time.UTC)
+	loaded.RecordEvicted(pdhelper.Store{Id: 4, Address: "10.0.0.4:20160"}, "evict-leader", "node 10.0.0.4 is unhealthy", now)
+	loaded.RecordWeightLowered(pdhelper.Store{Id: 5, Address: "10.0.0.5:20160"}, pdhelper.StoreWeight{Leader: 1, Region: 1}, now)
+	if err := store.Save(loaded); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(reloaded, loaded) {
+		t.Errorf("Load() = %+v, want %+v", reloaded, loaded)
+	}
+	if !reloaded.Owns("evict-leader", 4) {
+		t.Errorf("Owns() = false, want true")
+	}
+	if want := map[uint]pdhelper.StoreWeight{5: {Leader: 1, Region: 1}}; !reflect.DeepEqual(reloaded.LoweredWeights(), want) {
+		t.Errorf("LoweredWeights() = %v, want %v", reloaded.LoweredWeights(), want)
+	}
+}
+
+func TestState_Lifecycle(t *testing.T) {
+	now := time.Date(2020, 11, 17, 8, 0, 0, 0, time.UTC)
+	store := pdhelper.Store{Id: 4, Address: "10.0.0.4:20160"}
+	current := NewState()
+
+	current.RecordEvicted(store, "evict-leader", "unhealthy", now)
+	current.RecordRecovered(store, now.Add(time.Minute))
+	current.RecordEvicted(store, "evict-leader", "unhealthy", now.Add(2*time.Minute))
+
+	item := current.Stores[4]
+	if item.Evictions != 2 {
+		t.Errorf("Evictions = %d, want 2", item.Evictions)
+	}
+	if !item.RecoveredAt.Equal(now.Add(time.Minute)) || !item.EvictedAt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("RecoveredAt = %v, EvictedAt = %v", item.RecoveredAt, item.EvictedAt)
+	}
+	var types []string
+	for _, event := range item.History {
+		types = append(types, event.Type)
+	}
+	if want := []string{EventEvicted, EventRecovered, EventEvicted}; !reflect.DeepEqual(types, want) {
+		t.Errorf("History = %v, want %v", types, want)
+	}
+
+	// pd does not evict store 4 any more
+	forgotten := current.Reconcile(map[string][]uint{"evict-leader": {5}}, now.Add(3*time.Minute))
+	if want := []pdhelper.Store{store}; !reflect.DeepEqual(forgotten, want) {
+		t.Errorf("Reconcile() = %v, want %v", forgotten, want)
+	}
+	if current.Owns("evict-leader", 4) {
+		t.Errorf("Owns() = true, want false")
+	}
+	if last := item.History[len(item.History)-1]; last.Type != EventForgotten {
+		t.Errorf("last event = %v, want %v", last.Type, EventForgotten)
+	}
+}
+
+func TestState_RecordFlapped(t *testing.T) {
+	now := time.Now()
+	store := pdhelper.Store{Id: 1, Address: "10.0.0.1:20160"}
+	current := NewState()
+	current.RecordFlapped(store, time.Hour, now)
+	current.RecordFlapped(store, time.Hour, now.Add(10*time.Minute))
+	current.RecordFlapped(store, time.Hour, now.Add(20*time.Minute))
+	item := current.Stores[1]
+	tests := []struct {
+		name  string
+		at    time.Time
+		decay time.Duration
+		want  uint
+	}{
+		{"latest", now.Add(20 * time.Minute), time.Hour, 3},
+		{"one forgiven", now.Add(90 * time.Minute), time.Hour, 2},
+		{"all forgiven", now.Add(5 * time.Hour), time.Hour, 0},
+		{"never forgiven", now.Add(5 * time.Hour), 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := item.FlapsAt(tt.at, tt.decay); got != tt.want {
+				t.Errorf("FlapsAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// flaps forgiven before the next flap
+	current.RecordFlapped(store, time.Hour, now.Add(20*time.Minute+2*time.Hour))
+	if item.Flaps != 2 {
+		t.Errorf("Flaps = %v, want 2", item.Flaps)
+	}
+}
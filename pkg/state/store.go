@@ -0,0 +1,91 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Store persists the State of evictor.
+type Store interface {
+	Load() (*State, error)
+	Save(state *State) error
+}
+
+// NewStore returns a FileStore for path, or a MemoryStore if path is empty.
+func NewStore(path string) Store {
+	if path == "" {
+		return &MemoryStore{}
+	}
+	return &FileStore{Path: path}
+}
+
+// MemoryStore keeps the State in memory only, so it would be lost after restart.
+type MemoryStore struct {
+	content []byte
+}
+
+func (it *MemoryStore) Load() (*State, error) {
+	if it.content == nil {
+		return NewState(), nil
+	}
+	return decode(it.content)
+}
+
+func (it *MemoryStore) Save(state *State) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	it.content = content
+	return nil
+}
+
+// FileStore keeps the State as a json file.
+type FileStore struct {
+	Path string
+}
+
+func (it *FileStore) Load() (*State, error) {
+	content, err := ioutil.ReadFile(it.Path)
+	if os.IsNotExist(err) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(content)
+}
+
+func (it *FileStore) Save(state *State) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file then rename, so a crash would never leave a broken file
+	temp, err := ioutil.TempFile(filepath.Dir(it.Path), filepath.Base(it.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), it.Path)
+}
+
+func decode(content []byte) (*State, error) {
+	result := NewState()
+	if err := json.Unmarshal(content, result); err != nil {
+		return nil, err
+	}
+	if result.Stores == nil {
+		result.Stores = make(map[uint]*StoreState)
+	}
+	return result, nil
+}